@@ -1,31 +1,111 @@
 package ftp
 
-type responseCode string
+// ReplyCode is the three digit code at the start of every reply of an FTP
+// server. The first digit tells whether the command succeeded, see the methods
+// of ReplyCode.
+type ReplyCode int
 
+// Reply codes of RFC 959 (FTP).
 const (
-	commandOk                        responseCode = "200"
-	systemStatusOrHelpReply                       = "211"
-	directoryStatus                               = "212"
-	fileStatus                                    = "213"
-	helpMessage                                   = "214"
-	systemName                                    = "215"
-	serviceReadyForNewUser                        = "220"
-	serviceClosingControlConnection               = "221"
-	noTransferInProgress                          = "225"
-	closingDataConnection                         = "226"
-	enteringPassiveMode                           = "227"
-	userLoggedIn_Proceed                          = "230"
-	fileActionCompleted                           = "250"
-	pathNameCreated                               = "257"
-	userNameOK_NeedPassword                       = "331"
-	needAccountForLogin                           = "332"
-	fileActionPending                             = "350"
-	connectionClosed_TransferAborter              = "426"
+	CodeRestartMarker                   ReplyCode = 110
+	CodeServiceReadyInMinutes           ReplyCode = 120
+	CodeDataConnectionAlreadyOpen       ReplyCode = 125
+	CodeFileStatusOK                    ReplyCode = 150
+	CodeCommandOK                       ReplyCode = 200
+	CodeCommandSuperfluous              ReplyCode = 202
+	CodeSystemStatus                    ReplyCode = 211
+	CodeDirectoryStatus                 ReplyCode = 212
+	CodeFileStatus                      ReplyCode = 213
+	CodeHelpMessage                     ReplyCode = 214
+	CodeSystemType                      ReplyCode = 215
+	CodeServiceReady                    ReplyCode = 220
+	CodeServiceClosingControlConnection ReplyCode = 221
+	CodeDataConnectionOpen              ReplyCode = 225
+	CodeClosingDataConnection           ReplyCode = 226
+	CodeEnteringPassiveMode             ReplyCode = 227
+	CodeUserLoggedIn                    ReplyCode = 230
+	CodeFileActionOK                    ReplyCode = 250
+	CodePathCreated                     ReplyCode = 257
+	CodeUserNameOK                      ReplyCode = 331
+	CodeNeedAccountForLogin             ReplyCode = 332
+	CodeFileActionPending               ReplyCode = 350
+	CodeServiceNotAvailable             ReplyCode = 421
+	CodeCannotOpenDataConnection        ReplyCode = 425
+	CodeConnectionClosedTransferAborted ReplyCode = 426
+	CodeFileBusy                        ReplyCode = 450
+	CodeLocalError                      ReplyCode = 451
+	CodeInsufficientStorage             ReplyCode = 452
+	CodeSyntaxError                     ReplyCode = 500
+	CodeSyntaxErrorInParameters         ReplyCode = 501
+	CodeCommandNotImplemented           ReplyCode = 502
+	CodeBadSequenceOfCommands           ReplyCode = 503
+	CodeParameterNotImplemented         ReplyCode = 504
+	CodeNotLoggedIn                     ReplyCode = 530
+	CodeNeedAccountForStoring           ReplyCode = 532
+	CodeFileUnavailable                 ReplyCode = 550
+	CodePageTypeUnknown                 ReplyCode = 551
+	CodeExceededStorageAllocation       ReplyCode = 552
+	CodeFileNameNotAllowed              ReplyCode = 553
 )
 
-func (c responseCode) ok() bool {
-	if len(c) != 3 {
-		return false
-	}
-	return c[0] == '1' || c[0] == '2'
+// Reply codes of RFC 2228 (FTP Security Extensions).
+const (
+	CodeUserLoggedInAuthorized          ReplyCode = 232
+	CodeSecurityDataExchangeComplete    ReplyCode = 234
+	CodeSecurityDataExchangeSucceeded   ReplyCode = 235
+	CodeSecurityMechanismOK             ReplyCode = 334
+	CodeSecurityDataAcceptable          ReplyCode = 335
+	CodeUserNameOKNeedChallengeResponse ReplyCode = 336
+	CodeNeedUnavailableResource         ReplyCode = 431
+	CodeCommandProtectionDenied         ReplyCode = 533
+	CodeRequestDeniedForPolicy          ReplyCode = 534
+	CodeFailedSecurityCheck             ReplyCode = 535
+	CodeProtectionLevelNotSupported     ReplyCode = 536
+	CodeCommandProtectionNotSupported   ReplyCode = 537
+	CodeIntegrityProtectedReply         ReplyCode = 631
+	CodeConfidentialityIntegrityReply   ReplyCode = 632
+	CodeConfidentialityProtectedReply   ReplyCode = 633
+)
+
+// Reply codes of RFC 2428 (FTP Extensions for IPv6 and NATs).
+const (
+	CodeEnteringExtendedPassiveMode ReplyCode = 229
+	CodeNetworkProtocolNotSupported ReplyCode = 522
+)
+
+// RFC 3659 (Extensions to FTP) does not define new reply codes. SIZE and MDTM
+// reply with CodeFileStatus, MLST with CodeFileActionOK and a failed REST with
+// CodeSyntaxErrorInParameters.
+
+// Preliminary is true for codes 1xx. The command was accepted and the server
+// will send another reply once it is done.
+func (c ReplyCode) Preliminary() bool {
+	return 100 <= c && c <= 199
+}
+
+// Completion is true for codes 2xx. The command succeeded.
+func (c ReplyCode) Completion() bool {
+	return 200 <= c && c <= 299
+}
+
+// Intermediate is true for codes 3xx. The command was accepted but the server
+// needs another command to complete it, e.g. PASS after USER.
+func (c ReplyCode) Intermediate() bool {
+	return 300 <= c && c <= 399
+}
+
+// Transient is true for codes 4xx. The command failed but the error is
+// temporary, trying it again later might succeed.
+func (c ReplyCode) Transient() bool {
+	return 400 <= c && c <= 499
+}
+
+// Permanent is true for codes 5xx. The command failed and trying it again
+// will fail as well.
+func (c ReplyCode) Permanent() bool {
+	return 500 <= c && c <= 599
+}
+
+func (c ReplyCode) ok() bool {
+	return c.Preliminary() || c.Completion()
 }
@@ -0,0 +1,144 @@
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Config holds the settings for a connection to an FTP server, see
+// ConnectConfig. The zero value is a valid configuration for a plain FTP
+// connection without time-outs.
+type Config struct {
+	// Logger, if not nil, is passed all messages that are sent and received
+	// over the control connection.
+	Logger Logger
+	// TLSConfig, if not nil, makes the connection use implicit FTPS, see
+	// ConnectTLS.
+	TLSConfig *tls.Config
+	// Dial, if not nil, is used to establish the control connection and all
+	// data connections in passive mode instead of a net.Dialer. The network is
+	// always "tcp". The context is only valid while dialing. This can be used
+	// to route connections through a proxy, to bind them to a specific local
+	// address or to connect to an in-memory server in tests.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+	// DialTimeout limits how long it may take to establish the control
+	// connection and each data connection. For implicit FTPS, connecting and
+	// the TLS handshake together have to finish within this time. 0 means no
+	// time-out.
+	DialTimeout time.Duration
+	// ReplyTimeout limits how long it may take to send a command and to
+	// receive the server's reply on the control connection. 0 means no
+	// time-out.
+	ReplyTimeout time.Duration
+	// DataTimeout limits how long a data connection may be idle, i.e. how long
+	// a single read or write may take during a transfer. In active mode it
+	// also limits how long to wait for the server to connect. 0 means no
+	// time-out.
+	DataTimeout time.Duration
+}
+
+// ConnectConfig establishes a connection to the given host on the given port
+// with the given settings. The context is only used while connecting,
+// cancelling it afterwards does not affect the returned Connection. Use
+// WithContext for that.
+// The standard FTP port is 21, for implicit FTPS it is 990.
+func ConnectConfig(ctx context.Context, host string, port uint16, config Config) (*Connection, error) {
+	dialCtx, cancel := config.withDialTimeout(ctx)
+	defer cancel()
+	addr := net.JoinHostPort(host, strconv.Itoa(int(port)))
+	conn, err := config.dial(dialCtx, addr)
+	if err != nil {
+		return nil, err
+	}
+	c, err := connectOn(ctx, dialCtx, conn, host, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// ConnectConfigOn uses the given connection as an FTP control connection with
+// the given settings. The DialTimeout of the configuration is only used for
+// data connections and the TLS handshake of implicit FTPS.
+func ConnectConfigOn(conn net.Conn, config Config) (*Connection, error) {
+	ctx := context.Background()
+	dialCtx, cancel := config.withDialTimeout(ctx)
+	defer cancel()
+	return connectOn(ctx, dialCtx, conn, hostOf(conn.RemoteAddr()), config)
+}
+
+// withDialTimeout returns a context that is done once the DialTimeout has
+// passed, if there is one.
+func (config *Config) withDialTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if config.DialTimeout > 0 {
+		return context.WithTimeout(ctx, config.DialTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// dial connects to the given address using the configured Dial function or a
+// net.Dialer.
+func (config *Config) dial(ctx context.Context, addr string) (net.Conn, error) {
+	ctx, cancel := config.withDialTimeout(ctx)
+	defer cancel()
+	if config.Dial != nil {
+		return config.Dial(ctx, "tcp", addr)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", addr)
+}
+
+// connectOn starts the FTP session on an established control connection. The
+// TLS handshake of implicit FTPS has to finish before dialCtx is done, so
+// connecting and the handshake share the DialTimeout.
+func connectOn(ctx, dialCtx context.Context, conn net.Conn, host string, config Config) (*Connection, error) {
+	var tlsConfig *tls.Config
+	if config.TLSConfig != nil {
+		tlsConfig = tlsConfigFor(config.TLSConfig, host)
+		tlsConn, err := handshake(dialCtx, conn, tlsConfig, 0)
+		if err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	c, err := newConnection(ctx, conn, host, config)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		err = c.WithContext(ctx).protectDataConnections(tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// setControlDeadline limits the next I/O on the control connection to the
+// reply time-out, if there is one.
+func (c *Connection) setControlDeadline() {
+	if c.config.ReplyTimeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.config.ReplyTimeout))
+	}
+}
+
+// idleTimeoutConn extends the deadline before every read and write so the
+// connection only times out if it is idle for too long.
+type idleTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c idleTimeoutConn) Read(p []byte) (int, error) {
+	c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(p)
+}
+
+func (c idleTimeoutConn) Write(p []byte) (int, error) {
+	c.Conn.SetDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Write(p)
+}
@@ -0,0 +1,91 @@
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestIdleDataConnectionTimesOut(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	conn := idleTimeoutConn{client, 10 * time.Millisecond}
+
+	_, err := conn.Read(make([]byte, 1))
+	checkTimeout(t, err)
+}
+
+func TestReplyTimeoutLimitsWaitingForServer(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	c := &Connection{session: &session{
+		conn:   client,
+		config: Config{ReplyTimeout: 10 * time.Millisecond},
+	}}
+
+	_, _, err := c.receive()
+	checkTimeout(t, err)
+}
+
+func TestCustomDialIsUsedForControlAndDataConnections(t *testing.T) {
+	var dialed []string
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, network+" "+addr)
+		client, server := net.Pipe()
+		go server.Write([]byte("220 ready\r\n"))
+		return client, nil
+	}
+	c, err := ConnectConfig(
+		context.Background(), "ftp.example.com", 21, Config{Dial: dial})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	dataConn, err := c.dialData("ftp.example.com:2000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataConn.Close()
+
+	if len(dialed) != 2 ||
+		dialed[0] != "tcp ftp.example.com:21" ||
+		dialed[1] != "tcp ftp.example.com:2000" {
+		t.Errorf("unexpected connections: %v", dialed)
+	}
+}
+
+func TestDialAndImplicitTLSHandshakeShareDialTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go ioutil.ReadAll(server)
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		time.Sleep(300 * time.Millisecond)
+		return client, nil
+	}
+
+	start := time.Now()
+	_, err := ConnectConfig(context.Background(), "127.0.0.1", 990, Config{
+		Dial:        dial,
+		TLSConfig:   &tls.Config{},
+		DialTimeout: 400 * time.Millisecond,
+	})
+	if err == nil {
+		t.Fatal("expected handshake with silent server to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 600*time.Millisecond {
+		t.Errorf("dial and handshake took %v, longer than the DialTimeout", elapsed)
+	}
+}
+
+// test helpers
+
+func checkTimeout(t *testing.T, err error) {
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Errorf("expected time-out error but got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package ftp
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// WithContext returns a shallow copy of c which uses the given context for all
+// its operations. If the context is cancelled or its deadline expires while an
+// operation is running, the operation stops and returns the context's error.
+// Transfers that are interrupted like this are aborted so the connection can
+// still be used afterwards. If a command on the control connection is
+// interrupted however, the server's reply is lost and you should Close the
+// connection.
+// The returned Connection shares the control connection and all settings with
+// c. Only use one of them at a time.
+func (c *Connection) WithContext(ctx context.Context) *Connection {
+	if ctx == nil {
+		panic("nil context")
+	}
+	return &Connection{c.session, ctx}
+}
+
+// Context returns the context that is used for all operations on c. It is
+// context.Background unless c was created using WithContext.
+func (c *Connection) Context() context.Context {
+	return c.context()
+}
+
+func (c *Connection) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// aLongTimeAgo is a deadline that makes all blocking I/O return immediately.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// expire returns a function that makes all blocking I/O on conn return. It is
+// used to interrupt I/O on a connection that remains open afterwards.
+func expire(conn net.Conn) func() {
+	return func() { conn.SetDeadline(aLongTimeAgo) }
+}
+
+func (c *Connection) interruptOnCancel(interrupt func()) (stop func() (interrupted bool)) {
+	return interruptOnCancel(c.context(), interrupt)
+}
+
+// interruptOnCancel calls interrupt once the context is done. interrupt has
+// to make the blocking I/O return, e.g. by closing the connection or using
+// expire. Call stop after the I/O is finished, it reports whether the I/O was
+// interrupted.
+func interruptOnCancel(ctx context.Context, interrupt func()) (stop func() (interrupted bool)) {
+	if ctx.Done() == nil {
+		return func() bool { return false }
+	}
+	stopped := make(chan struct{})
+	interrupted := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			interrupt()
+			interrupted <- true
+		case <-stopped:
+			interrupted <- false
+		}
+	}()
+	return func() bool {
+		close(stopped)
+		return <-interrupted
+	}
+}
+
+// abortTimeout limits how long we wait for the server to acknowledge an abort
+// after the context of a transfer was cancelled.
+const abortTimeout = 10 * time.Second
+
+// abortTransfer closes the data connection of a transfer that was interrupted
+// because the context is done and tells the server to abort the transfer. It
+// returns the context's error.
+func (c *Connection) abortTransfer(dataConn net.Conn) error {
+	dataConn.Close()
+	return c.abort()
+}
+
+// abort tells the server to abort the transfer in progress after the context
+// is done and returns the context's error.
+func (c *Connection) abort() error {
+	c.conn.SetDeadline(time.Now().Add(abortTimeout))
+	c.WithContext(context.Background()).Abort()
+	c.conn.SetDeadline(time.Time{})
+	return c.ctx.Err()
+}
@@ -0,0 +1,156 @@
+package ftp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCancelledContextInterruptsBlockingIO(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Connection{session: &session{conn: client}, ctx: ctx}
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, _, err := c.receive()
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled but got %v", err)
+	}
+}
+
+func TestDoneContextFailsBeforeSending(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := (&Connection{session: &session{conn: client}}).WithContext(ctx)
+
+	err := c.send("NOOP")
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled but got %v", err)
+	}
+}
+
+func TestFinishedIOIsNotInterrupted(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := interruptOnCancel(ctx, expire(client))
+	if stop() {
+		t.Error("stopped without cancelling but was interrupted")
+	}
+}
+
+func TestConnectionsWithContextShareState(t *testing.T) {
+	c := &Connection{session: &session{}}
+	c.WithContext(context.Background()).SetActiveMode(ActiveModeSettings{})
+	if c.dataMode != activeMode {
+		t.Error("setting on derived connection is not shared")
+	}
+	if c.Context() != context.Background() {
+		t.Error("default context should be the background context")
+	}
+}
+
+func TestCancelledDownloadIsAborted(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	dataClient, dataServer := net.Pipe()
+	c.config.Dial = func(context.Context, string, string) (net.Conn, error) {
+		return dataClient, nil
+	}
+	commands := make(chan string, 10)
+	go func() {
+		r := bufio.NewReader(server)
+		replies := map[string][]string{
+			"TYPE": {"200 binary"},
+			"PASV": {"227 Entering Passive Mode (127,0,0,1,4,1)"},
+			"RETR": {"150 sending"},
+			"ABOR": {"426 transfer aborted", "226 abort successful"},
+			"NOOP": {"200 ok"},
+		}
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.Fields(line)[0]
+			commands <- cmd
+			for _, reply := range replies[cmd] {
+				server.Write([]byte(reply + "\r\n"))
+			}
+			if cmd == "RETR" {
+				go func() {
+					for {
+						if _, err := dataServer.Write([]byte("data")); err != nil {
+							return
+						}
+					}
+				}()
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := c.WithContext(ctx).Download("big.bin", cancellingWriter{cancel})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled but got %v", err)
+	}
+	if err := c.NoOperation(); err != nil {
+		t.Errorf("connection unusable after abort: %v", err)
+	}
+	close(commands)
+	var sent []string
+	for cmd := range commands {
+		sent = append(sent, cmd)
+	}
+	checkStrings(t, sent, "TYPE", "PASV", "RETR", "ABOR", "NOOP")
+}
+
+func TestCancelWhileWaitingForActiveDataConnectionAborts(t *testing.T) {
+	server := startTestServer(t)
+	server.setFile("file.txt", []byte("data"))
+	server.activeDelay = 200 * time.Millisecond
+	c := server.connect()
+	defer c.Close()
+	if err := c.Login("user", "pass"); err != nil {
+		t.Fatal(err)
+	}
+	c.SetActiveMode(ActiveModeSettings{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	err := c.WithContext(ctx).Download("file.txt", &bytes.Buffer{})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled but got %v", err)
+	}
+	if err := c.NoOperation(); err != nil {
+		t.Errorf("connection unusable after abort: %v", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	checkStrings(t, server.commands[len(server.commands)-3:],
+		"RETR file.txt", "ABOR", "NOOP")
+}
+
+// test helpers
+
+// cancellingWriter cancels a context on the first write.
+type cancellingWriter struct {
+	cancel context.CancelFunc
+}
+
+func (w cancellingWriter) Write(p []byte) (int, error) {
+	w.cancel()
+	return len(p), nil
+}
@@ -0,0 +1,412 @@
+package ftp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+type dataMode int
+
+const (
+	passiveMode dataMode = iota
+	extendedPassiveMode
+	activeMode
+)
+
+// ActiveModeSettings configures how data connections are established in
+// active mode, see SetActiveMode.
+type ActiveModeSettings struct {
+	// MinPort and MaxPort restrict the local ports on which the client listens
+	// for data connections, e.g. to match firewall rules. Both bounds are
+	// inclusive. If both are 0, any free port is used.
+	MinPort, MaxPort uint16
+	// Address is the IP address that is announced to the server. It defaults
+	// to the local address of the control connection. If you are behind a NAT,
+	// set this to your public address.
+	Address string
+}
+
+// SetActiveMode makes all following transfers and listings use active mode.
+// Before each transfer, the client listens on a local port and tells the
+// server its address. The server then connects to the client. This is useful
+// for servers behind firewalls that only allow active FTP.
+// The FTP command sent before each transfer is PORT for IPv4 or EPRT for IPv6
+// addresses.
+func (c *Connection) SetActiveMode(settings ActiveModeSettings) {
+	c.dataMode = activeMode
+	c.dataModeChosen = true
+	c.activeSettings = settings
+}
+
+// SetPassiveMode makes all following transfers and listings use passive mode,
+// which is the default. Before each transfer, the server tells the client an
+// address to which the client then connects.
+// The FTP command sent before each transfer is PASV. If the control connection
+// uses IPv6, EPSV is sent instead, see SetExtendedPassiveMode.
+// By default, i.e. if no mode was set, EPSV is also used if the server lists it
+// in its Features. Calling SetPassiveMode makes sure that PASV is used and its
+// address is handled according to the PassiveAddressPolicy.
+func (c *Connection) SetPassiveMode() {
+	c.dataMode = passiveMode
+	c.dataModeChosen = true
+}
+
+// SetExtendedPassiveMode makes all following transfers and listings use
+// extended passive mode as described in RFC 2428. It works like passive mode
+// but the server only tells the client a port, the client connects to the
+// same host as the control connection. This works with IPv4 and IPv6.
+// If the server does not implement EPSV, passive mode is used instead.
+// The FTP command sent before each transfer is EPSV.
+func (c *Connection) SetExtendedPassiveMode() {
+	c.dataMode = extendedPassiveMode
+	c.dataModeChosen = true
+}
+
+// SetExtendedPassiveModeAll tells the server that only extended passive mode
+// will be used from now on. This allows NATs and firewalls to handle the
+// connection without inspecting the FTP commands. Setting a different mode
+// after this call makes transfers and listings fail with an error until
+// SetExtendedPassiveMode is called again.
+// The FTP command this sends is EPSV ALL.
+func (c *Connection) SetExtendedPassiveModeAll() error {
+	err := c.execute(CodeCommandOK, "EPSV", "ALL")
+	if err != nil {
+		return err
+	}
+	c.dataMode = extendedPassiveMode
+	c.epsvAll = true
+	return nil
+}
+
+// errEPSVAll is returned for transfers in a mode other than extended passive
+// mode after SetExtendedPassiveModeAll.
+var errEPSVAll = errors.New("ftp: only extended passive mode may be used after EPSV ALL")
+
+// PassiveAddressPolicy decides how the IP address in the server's reply to PASV
+// is treated, see SetPassiveAddressPolicy.
+type PassiveAddressPolicy int
+
+const (
+	// IgnorePassiveAddress always connects to the control connection's peer.
+	// Only the port of the PASV reply is used, so addresses that are
+	// unroutable or differ from the peer are replaced. This is the default.
+	// It helps with servers behind a NAT that announce their internal address.
+	IgnorePassiveAddress PassiveAddressPolicy = iota
+	// ReplaceUnroutablePassiveAddress connects to the control connection's peer
+	// instead of the PASV address only if that is a private, loopback,
+	// link-local or unspecified address while the peer's address is not.
+	// Routable addresses that differ from the peer are used as is, e.g. for
+	// servers that hand out data connections on other hosts.
+	ReplaceUnroutablePassiveAddress
+	// TrustPassiveAddress connects to the PASV address as is.
+	TrustPassiveAddress
+	// RequirePeerAddress refuses data connections to or from any host other
+	// than the control connection's peer. This protects against servers that
+	// redirect data connections to third parties (FTP bounce attacks) and, in
+	// active mode, against third parties stealing the data connection.
+	RequirePeerAddress
+)
+
+// SetPassiveAddressPolicy sets how the address that the server replies to PASV
+// is used. The default is IgnorePassiveAddress.
+func (c *Connection) SetPassiveAddressPolicy(policy PassiveAddressPolicy) {
+	c.passiveAddressPolicy = policy
+}
+
+// applyPassiveAddressPolicy returns the address to connect to for the given
+// address from a PASV reply.
+func (c *Connection) applyPassiveAddressPolicy(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	ip := net.ParseIP(host)
+	peer := remoteIPOf(c.conn)
+	switch c.passiveAddressPolicy {
+	case ReplaceUnroutablePassiveAddress:
+		if peer != nil && !isRoutable(ip) && isRoutable(peer) {
+			ip = peer
+		}
+	case IgnorePassiveAddress:
+		if peer != nil {
+			ip = peer
+		}
+	case RequirePeerAddress:
+		if !ip.Equal(peer) {
+			return "", errors.New("refusing data connection to " + host +
+				" which is not the address of the FTP server")
+		}
+	}
+	return net.JoinHostPort(ip.String(), port), nil
+}
+
+// isRoutable is false for private, loopback, link-local and unspecified
+// addresses, which can only be reached from within the same network.
+func isRoutable(ip net.IP) bool {
+	return ip != nil &&
+		!ip.IsUnspecified() &&
+		!ip.IsLoopback() &&
+		!ip.IsLinkLocalUnicast() &&
+		!isPrivate(ip)
+}
+
+// isPrivate reports whether ip is in one of the private address ranges of RFC
+// 1918 (IPv4) or RFC 4193 (IPv6).
+func isPrivate(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4[0] == 10 ||
+			ip4[0] == 172 && ip4[1]&0xf0 == 16 ||
+			ip4[0] == 192 && ip4[1] == 168
+	}
+	return len(ip) == net.IPv6len && ip[0]&0xfe == 0xfc
+}
+
+func (c *Connection) dialData(addr string) (net.Conn, error) {
+	return c.config.dial(c.context(), addr)
+}
+
+// dataConnector establishes a data connection. It is prepared before a
+// transfer command is sent and connected after the server accepted it.
+type dataConnector interface {
+	// connect returns the established data connection. It stops waiting for
+	// the server once the context is done.
+	connect(ctx context.Context) (net.Conn, error)
+	// close releases all resources if connect is not called.
+	close()
+}
+
+func (c *Connection) prepareDataConnection() (dataConnector, error) {
+	if c.epsvAll && c.dataMode != extendedPassiveMode {
+		return nil, errEPSVAll
+	}
+	if c.dataMode == activeMode {
+		return c.enterActiveMode()
+	}
+	conn, err := c.enterPassiveMode()
+	if err != nil {
+		return nil, err
+	}
+	return passiveConnector{conn}, nil
+}
+
+func (c *Connection) useExtendedPassiveMode() bool {
+	if c.epsvAll {
+		return true
+	}
+	if c.epsvUnsupported {
+		return false
+	}
+	return c.dataMode == extendedPassiveMode || isIPv6(remoteIPOf(c.conn)) ||
+		!c.dataModeChosen && c.featureSet != nil && c.featureSet.Has("EPSV")
+}
+
+// enterExtendedPassiveMode sends EPSV and connects to the port the server
+// replied with. If the server does not know the EPSV command, supported is
+// false and the caller should fall back to PASV.
+func (c *Connection) enterExtendedPassiveMode() (conn net.Conn, supported bool, err error) {
+	resp, code, err := c.sendAndReceive("EPSV")
+	if err != nil {
+		return nil, true, err
+	}
+	if (code == CodeSyntaxError || code == CodeCommandNotImplemented) && !c.epsvAll {
+		c.epsvUnsupported = true
+		return nil, false, nil
+	}
+	if code != CodeEnteringExtendedPassiveMode {
+		return nil, true, errorMessage("EPSV", resp)
+	}
+	port, err := getPortOfEpsvResponse(resp)
+	if err != nil {
+		return nil, true, err
+	}
+	host := c.host
+	if ip := remoteIPOf(c.conn); ip != nil {
+		host = ip.String()
+	}
+	conn, err = c.dialData(net.JoinHostPort(host, strconv.Itoa(port)))
+	return conn, true, err
+}
+
+// getPortOfEpsvResponse extracts the port of a response like
+// 229 Entering Extended Passive Mode (|||6446|)
+// The delimiter | may be any printable character (RFC 2428).
+func getPortOfEpsvResponse(msg []byte) (int, error) {
+	start := bytes.IndexByte(msg, '(')
+	end := bytes.LastIndexByte(msg, ')')
+	if start == -1 || end-start < 6 {
+		return 0, errorMessage("port extraction", msg)
+	}
+	inner := msg[start+1 : end]
+	d := inner[0]
+	if inner[1] != d || inner[2] != d || inner[len(inner)-1] != d {
+		return 0, errorMessage("port extraction", msg)
+	}
+	port, err := strconv.ParseUint(string(inner[3:len(inner)-1]), 10, 16)
+	if err != nil || port == 0 {
+		return 0, errorMessage("port extraction", msg)
+	}
+	return int(port), nil
+}
+
+// passiveConnector is already connected to the server when it is created.
+type passiveConnector struct {
+	conn net.Conn
+}
+
+func (p passiveConnector) connect(context.Context) (net.Conn, error) {
+	return p.conn, nil
+}
+
+func (p passiveConnector) close() {
+	p.conn.Close()
+}
+
+// activeConnector waits for the server to connect. If peer is not nil, only
+// connections from that address are accepted. If timeout is not 0, it limits
+// how long to wait.
+type activeConnector struct {
+	listener *net.TCPListener
+	peer     net.IP
+	timeout  time.Duration
+}
+
+func (a activeConnector) connect(ctx context.Context) (net.Conn, error) {
+	defer a.listener.Close()
+	if a.timeout > 0 {
+		a.listener.SetDeadline(time.Now().Add(a.timeout))
+	}
+	stop := interruptOnCancel(ctx, func() { a.listener.Close() })
+	conn, err := a.listener.Accept()
+	if stop() {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if a.peer != nil && !a.peer.Equal(remoteIPOf(conn)) {
+		conn.Close()
+		return nil, errors.New("refusing data connection from " +
+			conn.RemoteAddr().String() +
+			" which is not the address of the FTP server")
+	}
+	return conn, nil
+}
+
+func (a activeConnector) close() {
+	a.listener.Close()
+}
+
+func (c *Connection) enterActiveMode() (dataConnector, error) {
+	localIP := localIPOf(c.conn)
+	listener, err := listenInRange(localIP,
+		c.activeSettings.MinPort, c.activeSettings.MaxPort)
+	if err != nil {
+		return nil, err
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	ip := localIP
+	if c.activeSettings.Address != "" {
+		ip = net.ParseIP(c.activeSettings.Address)
+		if ip == nil {
+			listener.Close()
+			return nil, errors.New("invalid active mode address: " +
+				c.activeSettings.Address)
+		}
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		err = c.execute(CodeCommandOK, "PORT", portArgument(ip4, port))
+	} else if ip != nil {
+		err = c.execute(CodeCommandOK, "EPRT", eprtArgument(ip, port))
+	} else {
+		err = errors.New("unable to determine the address for active mode, " +
+			"set ActiveModeSettings.Address")
+	}
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	var peer net.IP
+	if c.passiveAddressPolicy == RequirePeerAddress {
+		peer = remoteIPOf(c.conn)
+	}
+	return activeConnector{listener, peer, c.config.DataTimeout}, nil
+}
+
+// localIPOf returns the local IP address of the given connection or nil if it
+// is not a TCP connection.
+func localIPOf(conn net.Conn) net.IP {
+	if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		return addr.IP
+	}
+	return nil
+}
+
+// remoteIPOf returns the IP address of the peer of the given connection or nil
+// if it is not a TCP connection.
+func remoteIPOf(conn net.Conn) net.IP {
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP
+	}
+	return nil
+}
+
+func isIPv6(ip net.IP) bool {
+	return ip != nil && ip.To4() == nil
+}
+
+// listenInRange listens on the first free port between min and max
+// (inclusive). If both are 0, any free port is used. Otherwise a min of 0 means
+// port 1 because port 0 would let the system choose a port outside the range.
+// If ip is nil, the listener accepts connections on all local addresses.
+func listenInRange(ip net.IP, min, max uint16) (*net.TCPListener, error) {
+	host := ""
+	if ip != nil {
+		host = ip.String()
+	}
+	if min == 0 && max == 0 {
+		listener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+		if err != nil {
+			return nil, err
+		}
+		return listener.(*net.TCPListener), nil
+	}
+	if min == 0 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	var lastErr error
+	for port := int(min); port <= int(max); port++ {
+		addr := net.JoinHostPort(host, strconv.Itoa(port))
+		listener, err := net.Listen("tcp", addr)
+		if err == nil {
+			return listener.(*net.TCPListener), nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("no free port between %d and %d: %v",
+		min, max, lastErr)
+}
+
+// portArgument formats the IPv4 address and port for the PORT command as
+// h1,h2,h3,h4,p1,p2 (RFC 959).
+func portArgument(ip net.IP, port int) string {
+	return fmt.Sprintf("%d,%d,%d,%d,%d,%d",
+		ip[0], ip[1], ip[2], ip[3], port/256, port%256)
+}
+
+// eprtArgument formats the address and port for the EPRT command as
+// |protocol|address|port| (RFC 2428).
+func eprtArgument(ip net.IP, port int) string {
+	protocol := "2"
+	if ip.To4() != nil {
+		protocol = "1"
+	}
+	return "|" + protocol + "|" + ip.String() + "|" + strconv.Itoa(port) + "|"
+}
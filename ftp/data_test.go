@@ -0,0 +1,279 @@
+package ftp
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPORTargumentHasHostAndPort(t *testing.T) {
+	checkPORTargument(t, "0.0.0.0", 0, "0,0,0,0,0,0")
+	checkPORTargument(t, "127.12.0.1", 258, "127,12,0,1,1,2")
+	checkPORTargument(t, "192.168.1.2", 65535, "192,168,1,2,255,255")
+}
+
+func TestEPRTargumentHasProtocolHostAndPort(t *testing.T) {
+	checkEPRTargument(t, "132.235.1.2", 6275, "|1|132.235.1.2|6275|")
+	checkEPRTargument(t, "1080::8:800:200c:417a", 5282,
+		"|2|1080::8:800:200c:417a|5282|")
+}
+
+func TestListeningUsesPortInRange(t *testing.T) {
+	first, err := listenInRange(net.IPv4(127, 0, 0, 1), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	port := uint16(first.Addr().(*net.TCPAddr).Port)
+
+	_, err = listenInRange(net.IPv4(127, 0, 0, 1), port, port)
+	if err == nil {
+		t.Error("listening on a port in use should fail")
+	}
+}
+
+func TestMinPortZeroStillLimitsPortRange(t *testing.T) {
+	occupied, err := listenInRange(net.IPv4(127, 0, 0, 1), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer occupied.Close()
+	max := uint16(occupied.Addr().(*net.TCPAddr).Port)
+
+	listener, err := listenInRange(net.IPv4(127, 0, 0, 1), 0, max)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+	if port < 1 || port >= int(max) {
+		t.Errorf("expected port between 1 and %d but got %d", max-1, port)
+	}
+}
+
+func TestActiveModeDownload(t *testing.T) {
+	server := startTestServer(t)
+	server.setFile("file.txt", []byte("active data"))
+	c := activeTestConnection(t, server)
+	defer c.Close()
+
+	var buf bytes.Buffer
+	if err := c.Download("file.txt", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "active data" {
+		t.Errorf("unexpected data %q", buf.String())
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if !strings.HasPrefix(server.commands[len(server.commands)-2], "PORT 127,0,0,1,") {
+		t.Errorf("expected PORT before RETR but got %q", server.commands)
+	}
+}
+
+func TestActiveModeAcceptTimeoutKeepsControlConnectionInStep(t *testing.T) {
+	server := startTestServer(t)
+	server.setFile("file.txt", []byte("data"))
+	server.activeDelay = 200 * time.Millisecond
+	c := activeTestConnection(t, server)
+	defer c.Close()
+	c.config.DataTimeout = 20 * time.Millisecond
+
+	if err := c.Download("file.txt", &bytes.Buffer{}); err == nil {
+		t.Fatal("expected download to time out")
+	}
+	if err := c.NoOperation(); err != nil {
+		t.Errorf("control connection out of step after accept timeout: %v", err)
+	}
+}
+
+func TestActiveModeRefusesForeignPeerAndKeepsControlConnectionInStep(t *testing.T) {
+	server := startTestServer(t)
+	server.setFile("file.txt", []byte("data"))
+	server.activeFrom = "127.0.0.2"
+	c := activeTestConnection(t, server)
+	defer c.Close()
+	c.SetPassiveAddressPolicy(RequirePeerAddress)
+
+	err := c.Download("file.txt", &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "refusing data connection") {
+		t.Fatalf("expected foreign data connection to be refused but got %v", err)
+	}
+	if err := c.NoOperation(); err != nil {
+		t.Errorf("control connection out of step after refusing peer: %v", err)
+	}
+}
+
+func TestEPSVresponseHasPort(t *testing.T) {
+	checkEPSVport(t, "229 Entering Extended Passive Mode (|||6446|)\r\n", 6446)
+	checkEPSVport(t, "229 (!!!1!)\r\n", 1)
+	checkEPSVport(t, "229-multi\r\n229 ok (|||65535|)\r\n", 65535)
+	checkInvalidEPSVresponse(t, "229 Entering Extended Passive Mode\r\n")
+	checkInvalidEPSVresponse(t, "229 (|||)\r\n")
+	checkInvalidEPSVresponse(t, "229 (||6446|)\r\n")
+	checkInvalidEPSVresponse(t, "229 (|||6446!)\r\n")
+	checkInvalidEPSVresponse(t, "229 (|||65536|)\r\n")
+	checkInvalidEPSVresponse(t, "229 (|||0|)\r\n")
+}
+
+func TestEPSVisUsedForIPv6(t *testing.T) {
+	checkUsesEPSV(t, &session{conn: fakeConn{ip: "::1"}}, true)
+	checkUsesEPSV(t, &session{conn: fakeConn{ip: "127.0.0.1"}}, false)
+	checkUsesEPSV(t, &session{
+		conn:     fakeConn{ip: "127.0.0.1"},
+		dataMode: extendedPassiveMode,
+	}, true)
+	checkUsesEPSV(t, &session{
+		conn:            fakeConn{ip: "::1"},
+		epsvUnsupported: true,
+	}, false)
+	checkUsesEPSV(t, &session{
+		conn:     fakeConn{ip: "127.0.0.1"},
+		dataMode: activeMode,
+		epsvAll:  true,
+	}, true)
+}
+
+func TestAdvertisedEPSVIsOnlyUsedIfNoModeWasChosen(t *testing.T) {
+	c := &Connection{session: &session{
+		conn:       fakeConn{ip: "127.0.0.1"},
+		featureSet: parseFeatures([]byte("211-Features:\r\n EPSV\r\n211 End\r\n")),
+	}}
+	if !c.useExtendedPassiveMode() {
+		t.Error("expected advertised EPSV to be used by default")
+	}
+	c.SetPassiveMode()
+	if c.useExtendedPassiveMode() {
+		t.Error("expected PASV after SetPassiveMode")
+	}
+}
+
+func TestOtherModesFailAfterEPSVAll(t *testing.T) {
+	for _, mode := range []dataMode{passiveMode, activeMode} {
+		c := &Connection{session: &session{
+			conn:     fakeConn{ip: "127.0.0.1"},
+			dataMode: mode,
+			epsvAll:  true,
+		}}
+		if _, err := c.prepareDataConnection(); err != errEPSVAll {
+			t.Errorf("expected EPSV ALL error for mode %v but got %v", mode, err)
+		}
+	}
+}
+
+func TestMismatchingPassiveAddressIsReplacedByDefault(t *testing.T) {
+	c := &Connection{session: &session{conn: fakeConn{ip: "203.0.113.7"}}}
+	checkPassiveAddress(t, c, "198.51.100.1:2000", "203.0.113.7:2000")
+	checkPassiveAddress(t, c, "10.0.0.5:2000", "203.0.113.7:2000")
+	checkPassiveAddress(t, c, "203.0.113.7:2000", "203.0.113.7:2000")
+}
+
+func TestUnroutablePassiveAddressIsReplaced(t *testing.T) {
+	c := &Connection{session: &session{conn: fakeConn{ip: "203.0.113.7"}}}
+	c.SetPassiveAddressPolicy(ReplaceUnroutablePassiveAddress)
+	checkPassiveAddress(t, c, "10.0.0.5:2000", "203.0.113.7:2000")
+	checkPassiveAddress(t, c, "172.16.1.1:2000", "203.0.113.7:2000")
+	checkPassiveAddress(t, c, "192.168.1.1:2000", "203.0.113.7:2000")
+	checkPassiveAddress(t, c, "127.0.0.1:2000", "203.0.113.7:2000")
+	checkPassiveAddress(t, c, "0.0.0.0:2000", "203.0.113.7:2000")
+	checkPassiveAddress(t, c, "172.32.1.1:2000", "172.32.1.1:2000")
+	checkPassiveAddress(t, c, "198.51.100.1:2000", "198.51.100.1:2000")
+}
+
+func TestPassiveAddressIsKeptIfPeerIsUnroutableToo(t *testing.T) {
+	c := &Connection{session: &session{conn: fakeConn{ip: "192.168.0.2"}}}
+	c.SetPassiveAddressPolicy(ReplaceUnroutablePassiveAddress)
+	checkPassiveAddress(t, c, "10.0.0.5:2000", "10.0.0.5:2000")
+}
+
+func TestPassiveAddressPolicies(t *testing.T) {
+	c := &Connection{session: &session{conn: fakeConn{ip: "203.0.113.7"}}}
+	c.SetPassiveAddressPolicy(IgnorePassiveAddress)
+	checkPassiveAddress(t, c, "198.51.100.1:2000", "203.0.113.7:2000")
+	c.SetPassiveAddressPolicy(TrustPassiveAddress)
+	checkPassiveAddress(t, c, "10.0.0.5:2000", "10.0.0.5:2000")
+	c.SetPassiveAddressPolicy(RequirePeerAddress)
+	checkPassiveAddress(t, c, "203.0.113.7:2000", "203.0.113.7:2000")
+	_, err := c.applyPassiveAddressPolicy("198.51.100.1:2000")
+	if err == nil {
+		t.Error("expected strict policy to refuse foreign host")
+	}
+}
+
+// test helpers
+
+func checkPORTargument(t *testing.T, ip string, port int, expected string) {
+	arg := portArgument(net.ParseIP(ip).To4(), port)
+	if arg != expected {
+		t.Errorf("PORT expected %v but was %v", expected, arg)
+	}
+}
+
+func checkEPRTargument(t *testing.T, ip string, port int, expected string) {
+	arg := eprtArgument(net.ParseIP(ip), port)
+	if arg != expected {
+		t.Errorf("EPRT expected %v but was %v", expected, arg)
+	}
+}
+
+func checkEPSVport(t *testing.T, msg string, expected int) {
+	port, err := getPortOfEpsvResponse([]byte(msg))
+	if err != nil {
+		t.Errorf("got error %v", err.Error())
+	}
+	if port != expected {
+		t.Errorf("EPSV expected port %v but was %v", expected, port)
+	}
+}
+
+func checkInvalidEPSVresponse(t *testing.T, msg string) {
+	_, err := getPortOfEpsvResponse([]byte(msg))
+	if err == nil {
+		t.Errorf("expected error for %q", msg)
+	}
+}
+
+func checkPassiveAddress(t *testing.T, c *Connection, pasv, expected string) {
+	addr, err := c.applyPassiveAddressPolicy(pasv)
+	if err != nil {
+		t.Errorf("got error %v", err.Error())
+	}
+	if addr != expected {
+		t.Errorf("PASV %v expected %v but was %v", pasv, expected, addr)
+	}
+}
+
+func checkUsesEPSV(t *testing.T, s *session, expected bool) {
+	c := &Connection{session: s}
+	if c.useExtendedPassiveMode() != expected {
+		t.Errorf("expected EPSV usage to be %v", expected)
+	}
+}
+
+// fakeConn is a net.Conn with a TCP address that cannot transfer data.
+type fakeConn struct {
+	net.Conn
+	ip string
+}
+
+func (c fakeConn) LocalAddr() net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP(c.ip), Port: 50000}
+}
+
+func (c fakeConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP(c.ip), Port: 21}
+}
+
+// activeTestConnection returns a logged in Connection to the server that uses
+// active mode.
+func activeTestConnection(t *testing.T, server *testServer) *Connection {
+	c := server.connect()
+	if err := c.Login("user", "pass"); err != nil {
+		c.Close()
+		t.Fatal(err)
+	}
+	c.SetActiveMode(ActiveModeSettings{})
+	return c
+}
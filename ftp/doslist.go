@@ -0,0 +1,112 @@
+package ftp
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDOSListing parses the output of LIST on Windows servers like IIS that
+// format it like "dir" on MS-DOS, e.g.
+//
+//	10-16-26  03:45PM       <DIR>          docs
+//	01-02-2020  14:05            1,234 my file.txt
+//
+// Dates are month-day-year with two or four digit years, times use 12 or 24
+// hour clocks and sizes may contain thousands separators. Lines that cannot be
+// parsed are skipped. Times are interpreted as UTC because the listing does not
+// contain the server's time zone.
+func ParseDOSListing(listing string) []*Entry {
+	return parseListingWith(listing, parseDOSLine)
+}
+
+// parseDOSLine parses a single line of a DOS listing. The columns are date,
+// time, either <DIR> or the size and the name, which may contain spaces.
+func parseDOSLine(line string) (*Entry, bool) {
+	fields := fieldsWithOffsets(line)
+	if len(fields) < 4 {
+		return nil, false
+	}
+	clock := fields[1].text
+	rest := fields[2:]
+	if strings.EqualFold(rest[0].text, "AM") || strings.EqualFold(rest[0].text, "PM") {
+		clock += rest[0].text
+		rest = rest[1:]
+	}
+	if len(rest) < 2 {
+		return nil, false
+	}
+	modTime, ok := parseDOSTime(fields[0].text, clock)
+	if !ok {
+		return nil, false
+	}
+	facts := map[string]string{"modify": modTime.Format("20060102150405")}
+	if strings.EqualFold(rest[0].text, "<DIR>") {
+		facts["type"] = "dir"
+	} else {
+		size := strings.NewReplacer(",", "", ".", "", "'", "").Replace(rest[0].text)
+		if _, err := strconv.ParseUint(size, 10, 64); err != nil {
+			return nil, false
+		}
+		facts["type"] = "file"
+		facts["size"] = size
+	}
+	name := line[rest[1].offset:]
+	if facts["type"] == "dir" && name == "." {
+		facts["type"] = "cdir"
+	}
+	if facts["type"] == "dir" && name == ".." {
+		facts["type"] = "pdir"
+	}
+	e := NewEntry(name, facts)
+	e.raw = line
+	return e, true
+}
+
+// parseDOSTime parses a date like "10-16-26" or "10/16/2026" and a time like
+// "03:45PM" or "15:45".
+func parseDOSTime(date, clock string) (time.Time, bool) {
+	parts := strings.FieldsFunc(date, func(r rune) bool { return r == '-' || r == '/' })
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	month, err1 := strconv.Atoi(parts[0])
+	day, err2 := strconv.Atoi(parts[1])
+	year, err3 := strconv.Atoi(parts[2])
+	if err1 != nil || err2 != nil || err3 != nil ||
+		month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, false
+	}
+	if len(parts[2]) == 2 {
+		year += 1900
+		if year < 1969 {
+			year += 100
+		}
+	} else if len(parts[2]) != 4 {
+		return time.Time{}, false
+	}
+
+	upper := strings.ToUpper(clock)
+	pm := strings.HasSuffix(upper, "PM")
+	twelveHour := pm || strings.HasSuffix(upper, "AM")
+	if twelveHour {
+		upper = upper[:len(upper)-2]
+	}
+	colon := strings.IndexByte(upper, ':')
+	if colon == -1 {
+		return time.Time{}, false
+	}
+	hour, err1 := strconv.Atoi(upper[:colon])
+	minute, err2 := strconv.Atoi(upper[colon+1:])
+	if err1 != nil || err2 != nil || hour > 23 || minute > 59 ||
+		twelveHour && (hour < 1 || hour > 12) {
+		return time.Time{}, false
+	}
+	if twelveHour {
+		hour %= 12
+		if pm {
+			hour += 12
+		}
+	}
+	return time.Date(year, time.Month(month), day, hour, minute, 0, 0, time.UTC), true
+}
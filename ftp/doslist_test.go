@@ -0,0 +1,45 @@
+package ftp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDOSListingIsParsedIntoEntries(t *testing.T) {
+	entries := ParseDOSListing("10-16-26  03:45PM       <DIR>          docs\r\n" +
+		"01-02-2020  14:05            1,234,567 my file.txt\r\n" +
+		"12-31-99  12:00AM                  12 old.txt\r\n" +
+		"06/01/2021  12:30 PM               0 empty.txt\r\n" +
+		" Volume in drive C has no label.\r\n")
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries but got %d", len(entries))
+	}
+
+	checkEntryKind(t, entries[0], "docs", EntryDir)
+	checkModTime(t, entries[0], time.Date(2026, 10, 16, 15, 45, 0, 0, time.UTC))
+
+	checkEntryKind(t, entries[1], "my file.txt", EntryFile)
+	checkModTime(t, entries[1], time.Date(2020, 1, 2, 14, 5, 0, 0, time.UTC))
+	if entries[1].Size() != 1234567 {
+		t.Errorf("expected size 1234567 but got %d", entries[1].Size())
+	}
+
+	checkEntryKind(t, entries[2], "old.txt", EntryFile)
+	checkModTime(t, entries[2], time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC))
+
+	checkEntryKind(t, entries[3], "empty.txt", EntryFile)
+	checkModTime(t, entries[3], time.Date(2021, 6, 1, 12, 30, 0, 0, time.UTC))
+}
+
+func TestInvalidDOSTimesAreRejected(t *testing.T) {
+	for _, line := range []string{
+		"13-01-20  03:45PM  <DIR>  month",
+		"01-01-20  13:45PM  <DIR>  hour",
+		"01-01-20  03:45PM  1x2  size",
+		"01-01-202  03:45PM  <DIR>  year",
+	} {
+		if _, ok := parseDOSLine(line); ok {
+			t.Errorf("expected %q to be rejected", line)
+		}
+	}
+}
@@ -0,0 +1,429 @@
+package ftp
+
+import (
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EntryKind tells what kind of file system object an Entry describes.
+type EntryKind int
+
+const (
+	// EntryFile is a regular file.
+	EntryFile EntryKind = iota
+	// EntryDir is a directory.
+	EntryDir
+	// EntryCurrentDir is the listed directory itself, MLSD calls it cdir.
+	EntryCurrentDir
+	// EntryParentDir is the parent of the listed directory, MLSD calls it
+	// pdir.
+	EntryParentDir
+	// EntrySymlink is a symbolic link, see Entry.Target.
+	EntrySymlink
+	// EntryOther is anything else, e.g. a device file.
+	EntryOther
+)
+
+func (k EntryKind) String() string {
+	switch k {
+	case EntryFile:
+		return "file"
+	case EntryDir:
+		return "dir"
+	case EntryCurrentDir:
+		return "cdir"
+	case EntryParentDir:
+		return "pdir"
+	case EntrySymlink:
+		return "symlink"
+	}
+	return "other"
+}
+
+// Entry describes a file or directory in a listing, see ListEntries and
+// EntryOf. Information that the server did not provide has its zero value.
+type Entry struct {
+	name        string
+	kind        EntryKind
+	size        int64
+	modTime     time.Time
+	perm        string
+	uniqueID    string
+	unixMode    fs.FileMode
+	hasUnixMode bool
+	owner       string
+	group       string
+	target      string
+	facts       map[string]string
+	raw         string
+}
+
+// NewEntry creates an entry from facts as described in RFC 3659, e.g.
+// "type=file", "size=1024", "modify=20200131235959" and "unix.mode=0644".
+// Fact names are case-insensitive. Besides the facts of RFC 3659, the facts
+// "unix.owner", "unix.group" and "unix.target" (of a symbolic link) are used.
+// This is useful for a ListingParser that converts its format into facts.
+func NewEntry(name string, facts map[string]string) *Entry {
+	e := &Entry{name: name, facts: make(map[string]string, len(facts))}
+	for fact, value := range facts {
+		e.facts[strings.ToLower(fact)] = value
+	}
+	e.kind, e.target = kindOfTypeFact(e.facts["type"])
+	if size, ok := e.facts["size"]; ok {
+		e.size, _ = strconv.ParseInt(size, 10, 64)
+	} else if size, ok := e.facts["sizd"]; ok {
+		e.size, _ = strconv.ParseInt(size, 10, 64)
+	}
+	e.modTime = parseMLSTTime(e.facts["modify"])
+	e.perm = e.facts["perm"]
+	e.uniqueID = e.facts["unique"]
+	if mode, err := strconv.ParseUint(e.facts["unix.mode"], 8, 32); err == nil {
+		e.unixMode = unixFileMode(mode)
+		e.hasUnixMode = true
+	}
+	e.owner = firstFact(e.facts, "unix.ownername", "unix.owner", "unix.uid")
+	e.group = firstFact(e.facts, "unix.groupname", "unix.group", "unix.gid")
+	if target, ok := e.facts["unix.target"]; ok {
+		e.target = target
+	}
+	return e
+}
+
+// kindOfTypeFact interprets the type fact. Symbolic links are listed as
+// OS.unix=symlink or OS.unix=slink, optionally followed by :target.
+func kindOfTypeFact(typ string) (kind EntryKind, target string) {
+	switch strings.ToLower(typ) {
+	case "file", "":
+		return EntryFile, ""
+	case "dir":
+		return EntryDir, ""
+	case "cdir":
+		return EntryCurrentDir, ""
+	case "pdir":
+		return EntryParentDir, ""
+	}
+	lower := strings.ToLower(typ)
+	for _, prefix := range []string{"os.unix=symlink", "os.unix=slink"} {
+		if strings.HasPrefix(lower, prefix) {
+			return EntrySymlink, strings.TrimPrefix(typ[len(prefix):], ":")
+		}
+	}
+	return EntryOther, ""
+}
+
+// parseMLSTTime parses a time value as YYYYMMDDHHMMSS[.sss] in UTC. It returns
+// the zero time if the value is invalid.
+func parseMLSTTime(value string) time.Time {
+	t, err := time.ParseInLocation("20060102150405", value, time.UTC)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// unixFileMode converts the octal mode of a Unix file to an fs.FileMode,
+// ignoring the file type bits.
+func unixFileMode(mode uint64) fs.FileMode {
+	m := fs.FileMode(mode & 0777)
+	if mode&04000 != 0 {
+		m |= fs.ModeSetuid
+	}
+	if mode&02000 != 0 {
+		m |= fs.ModeSetgid
+	}
+	if mode&01000 != 0 {
+		m |= fs.ModeSticky
+	}
+	return m
+}
+
+func firstFact(facts map[string]string, names ...string) string {
+	for _, name := range names {
+		if value, ok := facts[name]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// Name returns the name of the file without its directory.
+func (e *Entry) Name() string {
+	return e.name
+}
+
+// Kind tells whether the entry is a file, directory, symbolic link, etc.
+func (e *Entry) Kind() EntryKind {
+	return e.kind
+}
+
+// Size returns the size of a file in bytes.
+func (e *Entry) Size() int64 {
+	return e.size
+}
+
+// ModTime returns the time of the last modification of the file.
+func (e *Entry) ModTime() time.Time {
+	return e.modTime
+}
+
+// Perm returns the permissions of the logged in user for this file as
+// described in RFC 3659, e.g. "adfrw". Each letter allows one operation, e.g.
+// r allows retrieving the file and w allows storing it.
+func (e *Entry) Perm() string {
+	return e.perm
+}
+
+// UniqueID returns an identifier that is the same for all paths that lead to
+// the same file on the server.
+func (e *Entry) UniqueID() string {
+	return e.uniqueID
+}
+
+// UnixMode returns the Unix permission bits of the file and whether the server
+// sent them.
+func (e *Entry) UnixMode() (mode fs.FileMode, ok bool) {
+	return e.unixMode, e.hasUnixMode
+}
+
+// Owner returns the name or ID of the user that owns the file on a Unix
+// server.
+func (e *Entry) Owner() string {
+	return e.owner
+}
+
+// Group returns the name or ID of the group that owns the file on a Unix
+// server.
+func (e *Entry) Group() string {
+	return e.group
+}
+
+// Target returns the path a symbolic link points to, if the server sent it.
+func (e *Entry) Target() string {
+	return e.target
+}
+
+// Fact returns the value of the given fact, e.g. "size" or "unix.mode", and
+// whether the server sent it. Fact names are case-insensitive.
+func (e *Entry) Fact(name string) (value string, ok bool) {
+	value, ok = e.facts[strings.ToLower(name)]
+	return
+}
+
+// Raw returns the line of the listing that describes this entry.
+func (e *Entry) Raw() string {
+	return e.raw
+}
+
+// Entry implements fs.FileInfo and fs.DirEntry so listings can be used like the
+// results of os.ReadDir and os.Stat.
+var (
+	_ fs.FileInfo = (*Entry)(nil)
+	_ fs.DirEntry = (*Entry)(nil)
+)
+
+// IsDir reports whether the entry is a directory, including the listed
+// directory itself and its parent.
+func (e *Entry) IsDir() bool {
+	return e.kind == EntryDir || e.kind == EntryCurrentDir || e.kind == EntryParentDir
+}
+
+// Mode returns the type and permission bits of the file. The permissions are
+// the Unix mode if the server sent it. Otherwise they are derived from Perm: a
+// readable file has 0444, a writable one 0200 and listable directories 0555.
+// If neither is known, files have 0644 and directories 0755.
+func (e *Entry) Mode() fs.FileMode {
+	return e.Type() | e.permBits()
+}
+
+// Type returns the type bits of Mode, e.g. fs.ModeDir or fs.ModeSymlink.
+func (e *Entry) Type() fs.FileMode {
+	if e.IsDir() {
+		return fs.ModeDir
+	}
+	if e.kind == EntrySymlink {
+		return fs.ModeSymlink
+	}
+	if e.kind == EntryFile {
+		return 0
+	}
+	typ := strings.ToLower(e.facts["type"])
+	switch {
+	case strings.HasPrefix(typ, "os.unix=chr"):
+		return fs.ModeDevice | fs.ModeCharDevice
+	case strings.HasPrefix(typ, "os.unix=blk"):
+		return fs.ModeDevice
+	case strings.HasPrefix(typ, "os.unix=fifo"):
+		return fs.ModeNamedPipe
+	case strings.HasPrefix(typ, "os.unix=socket"):
+		return fs.ModeSocket
+	}
+	return fs.ModeIrregular
+}
+
+func (e *Entry) permBits() fs.FileMode {
+	if e.hasUnixMode {
+		return e.unixMode
+	}
+	if e.perm == "" {
+		if e.IsDir() {
+			return 0755
+		}
+		return 0644
+	}
+	var mode fs.FileMode
+	if e.IsDir() && strings.ContainsAny(e.perm, "el") {
+		mode |= 0555
+	}
+	if !e.IsDir() && strings.ContainsRune(e.perm, 'r') {
+		mode |= 0444
+	}
+	if strings.ContainsAny(e.perm, "acmwf") {
+		mode |= 0200
+	}
+	return mode
+}
+
+// Sys returns the entry itself so that users of fs.FileInfo can access the raw
+// listing line and the facts, see Raw and Fact.
+func (e *Entry) Sys() interface{} {
+	return e
+}
+
+// Info returns the entry itself, it implements fs.DirEntry.
+func (e *Entry) Info() (fs.FileInfo, error) {
+	return e, nil
+}
+
+// entryOfMLSxLine parses a line of MLSD data or of a reply to MLST. It has the
+// form "fact1=value1;fact2=value2; name". The name may contain spaces and
+// semicolons. For MLST the name is a path of which only the last element is
+// kept.
+func entryOfMLSxLine(line string) (*Entry, bool) {
+	space := strings.IndexByte(line, ' ')
+	if space == -1 {
+		return nil, false
+	}
+	facts := make(map[string]string)
+	for _, fact := range strings.Split(line[:space], ";") {
+		if fact == "" {
+			continue
+		}
+		eq := strings.IndexByte(fact, '=')
+		if eq == -1 {
+			return nil, false
+		}
+		facts[fact[:eq]] = fact[eq+1:]
+	}
+	name := line[space+1:]
+	if strings.Contains(name, "/") && name != "/" {
+		name = path.Base(name)
+	}
+	e := NewEntry(name, facts)
+	e.raw = line
+	return e, true
+}
+
+// ListEntries returns the entries of the current working directory, see
+// ListEntriesIn.
+// The FTP commands this sends are FEAT and MLSD or SYST and LIST.
+func (c *Connection) ListEntries() ([]*Entry, error) {
+	return c.ListEntriesIn("")
+}
+
+// ListEntriesIn returns the entries of the directory at the given path. If the
+// server supports MLSD (RFC 3659), the listing has a standard format and the
+// entries carry typed information like their kind, size and modification time.
+// Use SelectMLSTFacts to choose which information the server sends.
+// Otherwise the output of LIST is parsed, see ParseListing. The server's reply
+// to SYST helps to detect its format.
+// The result may contain the directory itself (EntryCurrentDir) and its parent
+// (EntryParentDir).
+// The FTP commands this sends are FEAT and MLSD or SYST and LIST.
+func (c *Connection) ListEntriesIn(path string) ([]*Entry, error) {
+	features, err := c.Features()
+	if err != nil {
+		return nil, err
+	}
+	if features.Has("MLST") {
+		data, err := c.readListCommandData("MLSD", path)
+		if err == nil {
+			return parseMLSD(data), nil
+		}
+		if !isNotImplemented(err) {
+			return nil, err
+		}
+	}
+	system, err := c.cachedSystem()
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.readListCommandData("LIST", path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseListing(data, system), nil
+}
+
+// cachedSystem returns the server's reply to SYST. Since it never changes, it
+// is only requested once. If the server does not implement SYST, it is empty.
+func (c *Connection) cachedSystem() (string, error) {
+	if c.systemKnown {
+		return c.system, nil
+	}
+	system, err := c.System()
+	if err != nil && !isNotImplemented(err) {
+		return "", err
+	}
+	c.system, c.systemKnown = system, true
+	return system, nil
+}
+
+// EntryOf returns information about the file or directory at the given path.
+// It is sent over the control connection so no data connection is needed.
+// The FTP command this sends is MLST.
+func (c *Connection) EntryOf(path string) (*Entry, error) {
+	resp, err := c.executeGetResponse(CodeFileActionOK, "MLST", c.encodeName(path))
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(resp), "\r\n") {
+		if strings.HasPrefix(line, " ") {
+			if e, ok := entryOfMLSxLine(c.decodeName(line[1:])); ok {
+				return e, nil
+			}
+		}
+	}
+	return nil, errorMessage("entry extraction", resp)
+}
+
+// StatusEntriesOf returns the entries of the directory at the given path like
+// ListEntriesIn, but it asks for the listing with STAT on the control
+// connection instead of opening a data connection. Servers reply with the same
+// format as for LIST, see ParseListing.
+// The FTP commands this sends are SYST and STAT.
+func (c *Connection) StatusEntriesOf(path string) ([]*Entry, error) {
+	system, err := c.cachedSystem()
+	if err != nil {
+		return nil, err
+	}
+	_, status, err := c.StatusOf(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseListing(c.decodeName(status), system), nil
+}
+
+// parseMLSD parses the data of an MLSD listing, skipping invalid lines.
+func parseMLSD(data string) []*Entry {
+	var entries []*Entry
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if e, ok := entryOfMLSxLine(line); ok {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,119 @@
+package ftp
+
+import (
+	"io/fs"
+	"testing"
+	"time"
+)
+
+func TestMLSDListingIsParsedIntoEntries(t *testing.T) {
+	entries := parseMLSD("type=cdir;modify=20200101000000; .\r\n" +
+		"type=pdir;modify=20200101000000; ..\r\n" +
+		"type=file;size=1024;modify=20200131235959.123;perm=adfrw;unique=801U2; my file;1.txt\r\n" +
+		"Type=dir;Modify=20191224180000;UNIX.mode=0755;UNIX.owner=bob;UNIX.group=staff; docs\r\n" +
+		"type=OS.unix=slink:/var/log;unix.mode=0777; logs\r\n" +
+		"invalid line\r\n")
+	if len(entries) != 5 {
+		t.Fatalf("expected 5 entries but got %d", len(entries))
+	}
+	checkEntryKind(t, entries[0], ".", EntryCurrentDir)
+	checkEntryKind(t, entries[1], "..", EntryParentDir)
+
+	file := entries[2]
+	checkEntryKind(t, file, "my file;1.txt", EntryFile)
+	if file.Size() != 1024 {
+		t.Errorf("expected size 1024 but got %d", file.Size())
+	}
+	modTime := time.Date(2020, 1, 31, 23, 59, 59, 123000000, time.UTC)
+	if !file.ModTime().Equal(modTime) {
+		t.Errorf("expected mod time %v but got %v", modTime, file.ModTime())
+	}
+	if file.Perm() != "adfrw" || file.UniqueID() != "801U2" {
+		t.Errorf("unexpected perm %q or unique ID %q", file.Perm(), file.UniqueID())
+	}
+	if _, ok := file.UnixMode(); ok {
+		t.Error("expected no Unix mode")
+	}
+
+	dir := entries[3]
+	checkEntryKind(t, dir, "docs", EntryDir)
+	if mode, ok := dir.UnixMode(); !ok || mode != 0755 {
+		t.Errorf("expected Unix mode 0755 but got %v", mode)
+	}
+	if dir.Owner() != "bob" || dir.Group() != "staff" {
+		t.Errorf("unexpected owner %q or group %q", dir.Owner(), dir.Group())
+	}
+	if value, ok := dir.Fact("unix.MODE"); !ok || value != "0755" {
+		t.Errorf("expected fact 0755 but got %q", value)
+	}
+
+	link := entries[4]
+	checkEntryKind(t, link, "logs", EntrySymlink)
+	if link.Target() != "/var/log" {
+		t.Errorf("expected target /var/log but got %q", link.Target())
+	}
+	if link.Raw() != "type=OS.unix=slink:/var/log;unix.mode=0777; logs" {
+		t.Errorf("unexpected raw line %q", link.Raw())
+	}
+}
+
+func TestMLSTPathIsReducedToName(t *testing.T) {
+	e, ok := entryOfMLSxLine("type=file;size=3; /home/user/a.txt")
+	if !ok {
+		t.Fatal("expected valid line")
+	}
+	checkEntryKind(t, e, "a.txt", EntryFile)
+}
+
+func TestSpecialUnixModeBits(t *testing.T) {
+	mode := unixFileMode(04755)
+	if mode != fs.ModeSetuid|0755 {
+		t.Errorf("expected setuid and 0755 but got %v", mode)
+	}
+	mode = unixFileMode(01777)
+	if mode != fs.ModeSticky|0777 {
+		t.Errorf("expected sticky and 0777 but got %v", mode)
+	}
+}
+
+func TestEntriesAreFileInfos(t *testing.T) {
+	entries := parseMLSD("type=dir;perm=el; docs\r\n" +
+		"type=file;size=5;unix.mode=0640; notes.txt\r\n" +
+		"type=file;perm=r; readonly.txt\r\n" +
+		"type=OS.unix=slink:/tmp; tmp\r\n" +
+		"type=OS.unix=chr-13/29; tty\r\n")
+	if len(entries) != 5 {
+		t.Fatalf("expected 5 entries but got %d", len(entries))
+	}
+	checkMode(t, entries[0], fs.ModeDir|0555)
+	checkMode(t, entries[1], 0640)
+	checkMode(t, entries[2], 0444)
+	checkMode(t, entries[3], fs.ModeSymlink|0644)
+	checkMode(t, entries[4], fs.ModeDevice|fs.ModeCharDevice|0644)
+
+	var info fs.FileInfo = entries[1]
+	if info.IsDir() || info.Size() != 5 || info.Sys().(*Entry).Raw() != "type=file;size=5;unix.mode=0640; notes.txt" {
+		t.Errorf("unexpected file info %v", info)
+	}
+	var dirEntry fs.DirEntry = entries[0]
+	if !dirEntry.IsDir() || dirEntry.Type() != fs.ModeDir {
+		t.Errorf("expected directory but got type %v", dirEntry.Type())
+	}
+	if info, err := dirEntry.Info(); err != nil || info.Name() != "docs" {
+		t.Errorf("unexpected info %v, %v", info, err)
+	}
+}
+
+// test helpers
+
+func checkEntryKind(t *testing.T, e *Entry, name string, kind EntryKind) {
+	if e.Name() != name || e.Kind() != kind {
+		t.Errorf("expected %s %q but got %s %q", kind, name, e.Kind(), e.Name())
+	}
+}
+
+func checkMode(t *testing.T, e *Entry, mode fs.FileMode) {
+	if e.Mode() != mode {
+		t.Errorf("expected %s to have mode %v but was %v", e.Name(), mode, e.Mode())
+	}
+}
@@ -0,0 +1,161 @@
+package ftp
+
+import (
+	"errors"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// These errors are wrapped by *Error depending on the reply code so you can
+// check for them using errors.Is. ErrNotFound also matches fs.ErrNotExist,
+// ErrPermission and ErrNotLoggedIn also match fs.ErrPermission.
+var (
+	// ErrNotFound is wrapped for reply code 550 (file unavailable) unless the
+	// server's message says that access was denied.
+	ErrNotFound error = &sentinelError{"file not found", fs.ErrNotExist}
+	// ErrPermission is wrapped for reply code 553 (file name not allowed) and
+	// for 550 if the server's message says that access was denied.
+	ErrPermission error = &sentinelError{"permission denied", fs.ErrPermission}
+	// ErrNotLoggedIn is wrapped for reply codes 530 (not logged in) and 532
+	// (need account for storing files).
+	ErrNotLoggedIn error = &sentinelError{"not logged in", fs.ErrPermission}
+	// ErrServiceUnavailable is wrapped for reply code 421 (service not
+	// available, closing control connection).
+	ErrServiceUnavailable error = &sentinelError{"service not available", nil}
+	// ErrInsufficientStorage is wrapped for reply codes 452 (insufficient
+	// storage space) and 552 (exceeded storage allocation).
+	ErrInsufficientStorage error = &sentinelError{"insufficient storage space", nil}
+)
+
+// ErrTransferInProgress is returned when a command is sent while a stream of a
+// transfer, e.g. from Retrieve, is still open. Close the stream first.
+var ErrTransferInProgress = errors.New("ftp: transfer in progress, close it first")
+
+type sentinelError struct {
+	message string
+	fsErr   error
+}
+
+func (e *sentinelError) Error() string {
+	return "ftp: " + e.message
+}
+
+func (e *sentinelError) Unwrap() error {
+	return e.fsErr
+}
+
+// Error is returned if the FTP server replies to a command with an error code
+// or with a reply that cannot be used. Use errors.As to inspect it.
+type Error struct {
+	// Command is the FTP command that failed, e.g. "RETR".
+	Command string
+	// Code is the three digit reply code, e.g. 550. It is 0 if the reply did
+	// not start with a valid code.
+	Code ReplyCode
+	// Lines are the lines of the reply without reply codes and line breaks.
+	Lines []string
+}
+
+func newError(command string, response []byte) *Error {
+	return &Error{
+		Command: command,
+		Code:    extractCode(response),
+		Lines:   replyLines(response),
+	}
+}
+
+func (e *Error) Error() string {
+	return "FTP server responded to " + e.Command + " with error: " +
+		strconv.Itoa(int(e.Code)) + " " + strings.Join(e.Lines, "\n")
+}
+
+// Unwrap returns the sentinel error for the reply code, e.g. ErrNotFound for
+// 550, or nil if there is none.
+func (e *Error) Unwrap() error {
+	switch e.Code {
+	case CodeFileUnavailable:
+		if e.deniesAccess() {
+			return ErrPermission
+		}
+		return ErrNotFound
+	case CodeFileNameNotAllowed:
+		return ErrPermission
+	case CodeNotLoggedIn, CodeNeedAccountForStoring:
+		return ErrNotLoggedIn
+	case CodeServiceNotAvailable:
+		return ErrServiceUnavailable
+	case CodeInsufficientStorage, CodeExceededStorageAllocation:
+		return ErrInsufficientStorage
+	}
+	return nil
+}
+
+// deniesAccess reports whether the reply's message says that access was
+// denied. Servers use 550 for both missing files and missing permissions.
+// Only explicit denials count, the RFC 959 text for 550 mentions "no access"
+// in a list of possible reasons and must still mean that a file is missing.
+func (e *Error) deniesAccess() bool {
+	msg := strings.ToLower(strings.Join(e.Lines, " "))
+	for _, phrase := range accessDeniedPhrases {
+		if strings.Contains(msg, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+var accessDeniedPhrases = []string{
+	"permission denied",
+	"access denied",
+	"access is denied",
+	"not permitted",
+	"insufficient permission",
+}
+
+// Transient is true for reply codes 4xx which mean that the command failed but
+// the error is temporary, e.g. 421 Service not available or 450 File
+// unavailable. Trying the same command again later might succeed.
+func (e *Error) Transient() bool {
+	return e.Code.Transient()
+}
+
+// Permanent is true for reply codes 5xx which mean that the command failed
+// and trying it again will fail as well, e.g. 550 File not found.
+func (e *Error) Permanent() bool {
+	return e.Code.Permanent()
+}
+
+// IsTransient reports whether err is or wraps an *Error with a transient reply
+// code (4xx).
+func IsTransient(err error) bool {
+	var ftpErr *Error
+	return errors.As(err, &ftpErr) && ftpErr.Transient()
+}
+
+// IsPermanent reports whether err is or wraps an *Error with a permanent reply
+// code (5xx).
+func IsPermanent(err error) bool {
+	var ftpErr *Error
+	return errors.As(err, &ftpErr) && ftpErr.Permanent()
+}
+
+// replyLines splits the response into lines and strips the reply codes at the
+// start of the first and last line (and of other lines that repeat the code).
+func replyLines(response []byte) []string {
+	text := strings.TrimSuffix(string(response), "\r\n")
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\r\n")
+	code := strconv.Itoa(int(extractCode(response)))
+	for i, line := range lines {
+		if len(line) >= 4 && line[:3] == code &&
+			(line[3] == ' ' || line[3] == '-') {
+			lines[i] = line[4:]
+		} else if line == code {
+			lines[i] = ""
+		}
+	}
+	return lines
+}
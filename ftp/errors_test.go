@@ -0,0 +1,105 @@
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+)
+
+func TestErrorHasCommandCodeAndLines(t *testing.T) {
+	err := errorMessage("RETR", []byte("550 No such file.\r\n"))
+	var ftpErr *Error
+	if !errors.As(err, &ftpErr) {
+		t.Fatalf("expected *Error but got %T", err)
+	}
+	if ftpErr.Command != "RETR" || ftpErr.Code != 550 {
+		t.Errorf("unexpected command %v and code %v",
+			ftpErr.Command, ftpErr.Code)
+	}
+	checkLines(t, ftpErr.Lines, "No such file.")
+	if err.Error() != "FTP server responded to RETR with error: 550 No such file." {
+		t.Errorf("unexpected error message '%v'", err.Error())
+	}
+}
+
+func TestMultiLineErrorIsStrippedOfCodes(t *testing.T) {
+	err := newError("STAT", []byte("421-Too many users\r\nTry later\r\n421 Bye\r\n"))
+	checkLines(t, err.Lines, "Too many users", "Try later", "Bye")
+	err = newError("STAT", []byte("421-first\r\n421-second\r\n421\r\n"))
+	checkLines(t, err.Lines, "first", "second", "")
+}
+
+func TestReplyCodesAreTransientOrPermanent(t *testing.T) {
+	transient := errorMessage("STOR", []byte("452 Disk full\r\n"))
+	permanent := errorMessage("STOR", []byte("553 Bad file name\r\n"))
+	other := errorMessage("PWD", []byte("257 no quotes\r\n"))
+	wrapped := fmt.Errorf("upload failed: %w", transient)
+
+	if !IsTransient(transient) || IsPermanent(transient) {
+		t.Error("452 should be transient")
+	}
+	if IsTransient(permanent) || !IsPermanent(permanent) {
+		t.Error("553 should be permanent")
+	}
+	if IsTransient(other) || IsPermanent(other) {
+		t.Error("257 should be neither transient nor permanent")
+	}
+	if !IsTransient(wrapped) {
+		t.Error("wrapped errors should be recognized")
+	}
+	if IsTransient(errors.New("452")) {
+		t.Error("other errors should not be transient")
+	}
+}
+
+func TestReplyCodesMapToSentinelErrors(t *testing.T) {
+	checkIs(t, "550 No such file or directory\r\n", ErrNotFound)
+	checkIs(t, "550 No such file or directory\r\n", fs.ErrNotExist)
+	checkIs(t, "550 Permission denied\r\n", ErrPermission)
+	checkIs(t, "550 Access is denied.\r\n", fs.ErrPermission)
+	checkIs(t, "553 File name not allowed\r\n", ErrPermission)
+	checkIs(t, "530 Not logged in\r\n", ErrNotLoggedIn)
+	checkIs(t, "530 Not logged in\r\n", fs.ErrPermission)
+	checkIs(t, "532 Need account\r\n", ErrNotLoggedIn)
+	checkIs(t, "421 Service not available\r\n", ErrServiceUnavailable)
+	checkIs(t, "452 Insufficient storage\r\n", ErrInsufficientStorage)
+	checkIs(t, "552 Quota exceeded\r\n", ErrInsufficientStorage)
+}
+
+func TestUnmappedCodesHaveNoSentinelError(t *testing.T) {
+	err := errorMessage("CMD", []byte("500 Syntax error\r\n"))
+	if errors.Unwrap(err) != nil {
+		t.Errorf("500 should not wrap an error but wraps %v", errors.Unwrap(err))
+	}
+	if errors.Is(errorMessage("CMD", []byte("550 Permission denied\r\n")), ErrNotFound) {
+		t.Error("permission denied should not be ErrNotFound")
+	}
+}
+
+func TestAmbiguous550RepliesMeanNotFound(t *testing.T) {
+	rfc := "550 Requested action not taken. File unavailable" +
+		" (e.g., file not found, no access).\r\n"
+	checkIs(t, rfc, ErrNotFound)
+	checkIs(t, rfc, fs.ErrNotExist)
+	checkIs(t, "550 Can't access file.\r\n", ErrNotFound)
+	checkIs(t, "550 Operation not permitted\r\n", ErrPermission)
+	if errors.Is(errorMessage("CMD", []byte(rfc)), ErrPermission) {
+		t.Error("RFC 959 file unavailable text should not be ErrPermission")
+	}
+}
+
+// test helpers
+
+func checkIs(t *testing.T, response string, target error) {
+	err := errorMessage("CMD", []byte(response))
+	if !errors.Is(err, target) {
+		t.Errorf("%q should be %v", response, target)
+	}
+}
+
+func checkLines(t *testing.T, lines []string, expected ...string) {
+	if fmt.Sprint(lines) != fmt.Sprint(expected) || len(lines) != len(expected) {
+		t.Errorf("expected lines %q but were %q", expected, lines)
+	}
+}
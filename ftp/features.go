@@ -0,0 +1,186 @@
+package ftp
+
+import (
+	"sort"
+	"strings"
+)
+
+// Features are the extensions of the FTP protocol that a server supports, as
+// listed in its reply to FEAT (RFC 2389). Feature names are case-insensitive,
+// e.g. "MDTM", "SIZE", "MLST", "UTF8", "EPSV", "REST" or "HASH".
+type Features struct {
+	params map[string]string
+}
+
+// Features asks the server which extensions it supports. Servers that do not
+// implement FEAT have no features, this is not an error.
+// The result is cached until the session changes, i.e. on Login, Reinitialize
+// and AuthTLS, because servers may advertise different features then. The
+// connection uses the cached features to pick the best commands, e.g. to
+// prefer EPSV over PASV.
+// The FTP command this sends is FEAT.
+func (c *Connection) Features() (*Features, error) {
+	if c.featureSet != nil {
+		return c.featureSet, nil
+	}
+	resp, code, err := c.sendAndReceive("FEAT")
+	if err != nil {
+		return nil, err
+	}
+	if code == CodeSyntaxError || code == CodeCommandNotImplemented {
+		c.featureSet = &Features{params: map[string]string{}}
+	} else if code == CodeSystemStatus {
+		c.featureSet = parseFeatures(resp)
+	} else {
+		return nil, errorMessage("FEAT", resp)
+	}
+	return c.featureSet, nil
+}
+
+// parseFeatures extracts the features from a FEAT reply as described in
+// RFC 2389. Every feature is on its own line, starting with a space, the
+// feature name is followed by optional parameters. Features that are listed
+// more than once, like AUTH TLS and AUTH SSL, have their parameters joined by
+// semicolons.
+func parseFeatures(resp []byte) *Features {
+	params := make(map[string]string)
+	for _, line := range strings.Split(string(resp), "\r\n") {
+		if !strings.HasPrefix(line, " ") {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, param := line, ""
+		if i := strings.IndexByte(line, ' '); i != -1 {
+			name, param = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		name = strings.ToUpper(name)
+		if old, ok := params[name]; ok && old != "" && param != "" {
+			param = old + ";" + param
+		}
+		params[name] = param
+	}
+	return &Features{params: params}
+}
+
+// Has reports whether the server lists the given feature.
+func (f *Features) Has(name string) bool {
+	_, ok := f.params[strings.ToUpper(name)]
+	return ok
+}
+
+// Params returns the parameters of the given feature as the server sent them,
+// e.g. "STREAM" for REST or "type*;size*;modify*;" for MLST. It is empty if
+// the feature has no parameters or is not supported.
+func (f *Features) Params(name string) string {
+	return f.params[strings.ToUpper(name)]
+}
+
+// Names returns the upper case names of all features, sorted alphabetically.
+func (f *Features) Names() []string {
+	names := make([]string, 0, len(f.params))
+	for name := range f.params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RestartStream reports whether the server supports restarting transfers at a
+// byte offset, which is needed for DownloadFrom and ResumeDownload.
+func (f *Features) RestartStream() bool {
+	return f.Has("REST") && containsFold(f.paramList("REST"), "STREAM")
+}
+
+// UTF8 reports whether the server supports UTF-8 encoded path names.
+func (f *Features) UTF8() bool {
+	return f.Has("UTF8")
+}
+
+// AuthTLS reports whether the server supports explicit FTPS, see
+// Connection.AuthTLS.
+func (f *Features) AuthTLS() bool {
+	return containsFold(f.paramList("AUTH"), "TLS")
+}
+
+// MLSTFacts returns the lower case names of the facts that the server can
+// list for MLST and MLSD, e.g. "type", "size" and "modify". It is empty if
+// the server does not support MLST.
+func (f *Features) MLSTFacts() []string {
+	facts, _ := f.selectableParams("MLST")
+	return facts
+}
+
+// EnabledMLSTFacts returns the lower case names of the facts that the server
+// currently lists for MLST and MLSD.
+func (f *Features) EnabledMLSTFacts() []string {
+	_, enabled := f.selectableParams("MLST")
+	return enabled
+}
+
+// HashAlgorithms returns the names of the hash algorithms that the server
+// supports for the HASH command, e.g. "SHA-256" or "MD5".
+func (f *Features) HashAlgorithms() []string {
+	algorithms, _ := f.selectableParams("HASH")
+	return algorithms
+}
+
+// CurrentHashAlgorithm returns the hash algorithm that the server currently
+// uses for the HASH command or "" if it does not support HASH.
+func (f *Features) CurrentHashAlgorithm() string {
+	_, current := f.selectableParams("HASH")
+	if len(current) == 0 {
+		return ""
+	}
+	return current[0]
+}
+
+// paramList splits the parameters of a feature at semicolons.
+func (f *Features) paramList(name string) []string {
+	var list []string
+	for _, param := range strings.Split(f.Params(name), ";") {
+		if param = strings.TrimSpace(param); param != "" {
+			list = append(list, param)
+		}
+	}
+	return list
+}
+
+// selectableParams returns all options of a feature like MLST or HASH and the
+// ones that are currently selected, which the server marks with a *. MLST
+// fact names are converted to lower case.
+func (f *Features) selectableParams(name string) (all, selected []string) {
+	for _, param := range f.paramList(name) {
+		isSelected := strings.HasSuffix(param, "*")
+		param = strings.TrimSuffix(param, "*")
+		if name == "MLST" {
+			param = strings.ToLower(param)
+		}
+		all = append(all, param)
+		if isSelected {
+			selected = append(selected, param)
+		}
+	}
+	return
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// supportsRestartStream reports whether the server advertises REST STREAM,
+// i.e. whether transfers can be restarted at a byte offset.
+func (c *Connection) supportsRestartStream() (bool, error) {
+	features, err := c.Features()
+	if err != nil {
+		return false, err
+	}
+	return features.RestartStream(), nil
+}
@@ -0,0 +1,60 @@
+package ftp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFeaturesAreParsedFromFEATReply(t *testing.T) {
+	features := parseFeatures([]byte("211-Features:\r\n" +
+		" MDTM\r\n" +
+		" rest STREAM\r\n" +
+		" MLST Type*;Size*;modify*;perm;\r\n" +
+		" UTF8\r\n" +
+		"211 End\r\n"))
+	checkStrings(t, features.Names(), "MDTM", "MLST", "REST", "UTF8")
+	if !features.Has("mdtm") || features.Has("SIZE") {
+		t.Error("feature names should be case-insensitive")
+	}
+	if features.Params("REST") != "STREAM" || !features.RestartStream() {
+		t.Errorf("expected REST STREAM but got %q", features.Params("REST"))
+	}
+	if !features.UTF8() {
+		t.Error("expected UTF8")
+	}
+	checkStrings(t, features.MLSTFacts(), "type", "size", "modify", "perm")
+	checkStrings(t, features.EnabledMLSTFacts(), "type", "size", "modify")
+}
+
+func TestSingleLineFEATReplyHasNoFeatures(t *testing.T) {
+	features := parseFeatures([]byte("211 No features\r\n"))
+	if len(features.Names()) != 0 {
+		t.Errorf("expected no features but got %v", features.Names())
+	}
+	if features.RestartStream() || features.AuthTLS() {
+		t.Error("expected no capabilities")
+	}
+}
+
+func TestRepeatedFeaturesJoinParameters(t *testing.T) {
+	features := parseFeatures([]byte("211-Extensions:\r\n" +
+		" AUTH SSL\r\n" +
+		" AUTH TLS\r\n" +
+		" HASH SHA-1;SHA-256*;MD5\r\n" +
+		"211 END\r\n"))
+	if features.Params("AUTH") != "SSL;TLS" || !features.AuthTLS() {
+		t.Errorf("expected AUTH SSL;TLS but got %q", features.Params("AUTH"))
+	}
+	checkStrings(t, features.HashAlgorithms(), "SHA-1", "SHA-256", "MD5")
+	if features.CurrentHashAlgorithm() != "SHA-256" {
+		t.Errorf("expected SHA-256 but got %q", features.CurrentHashAlgorithm())
+	}
+}
+
+// test helpers
+
+func checkStrings(t *testing.T, actual []string, expected ...string) {
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %q but got %q", expected, actual)
+	}
+}
@@ -0,0 +1,235 @@
+package ftp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+)
+
+// FS provides read-only access to the files on an FTP server as an fs.FS, see
+// Connection.FS. It implements fs.ReadDirFS, fs.StatFS and fs.ReadFileFS so
+// it can be used with fs.WalkDir, fs.Glob, template.ParseFS or
+// http.FileServer(http.FS(...)).
+// Opened files are downloaded completely into memory, they can be read and
+// seeked without using the connection. An FS may be used by multiple
+// goroutines, the calls are serialized because a Connection can only do one
+// thing at a time. Do not use the Connection itself while the FS is in use.
+type FS struct {
+	mu   sync.Mutex
+	c    *Connection
+	root string
+}
+
+var (
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.StatFS     = (*FS)(nil)
+	_ fs.ReadFileFS = (*FS)(nil)
+)
+
+// FS returns a file system of the directory at the given root path on the
+// server. Names passed to the FS are relative to the root, which may be
+// absolute like "/pub" or relative to the working directory. An empty root is
+// the working directory.
+// Directories are listed with ListEntriesIn and files are read with Download.
+// Stat uses EntryOf if the server supports MLST, otherwise it lists the parent
+// directory.
+func (c *Connection) FS(root string) *FS {
+	return &FS{c: c, root: root}
+}
+
+// Open opens the named file or directory. The returned file implements
+// io.Seeker and io.ReaderAt for files and fs.ReadDirFile for directories.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	info, err := fsys.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if info.IsDir() {
+		return &dirFile{fsys: fsys, name: name, info: info}, nil
+	}
+	var buf bytes.Buffer
+	err = fsys.c.Download(fsys.path(name), &buf)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &file{Reader: bytes.NewReader(buf.Bytes()), info: info}, nil
+}
+
+// ReadFile downloads the named file.
+func (fsys *FS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	var buf bytes.Buffer
+	err := fsys.c.Download(fsys.path(name), &buf)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadDir lists the named directory, sorted by file name. The entries for the
+// directory itself and its parent are left out.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	entries, err := fsys.readDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return entries, nil
+}
+
+// Stat returns information about the named file or directory. The returned
+// fs.FileInfo is an *Entry.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	info, err := fsys.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+// path returns the path on the server for a valid name of the FS.
+func (fsys *FS) path(name string) string {
+	if name == "." {
+		return fsys.root
+	}
+	if fsys.root == "" {
+		return name
+	}
+	return path.Join(fsys.root, name)
+}
+
+func (fsys *FS) readDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fsys.c.ListEntriesIn(fsys.path(name))
+	if err != nil {
+		return nil, err
+	}
+	var list []fs.DirEntry
+	for _, e := range entries {
+		if isSelfOrParent(e) {
+			continue
+		}
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, nil
+}
+
+func (fsys *FS) stat(name string) (*Entry, error) {
+	if name == "." {
+		return NewEntry(".", map[string]string{"type": "dir"}), nil
+	}
+	features, err := fsys.c.Features()
+	if err != nil {
+		return nil, err
+	}
+	if features.Has("MLST") {
+		e, err := fsys.c.EntryOf(fsys.path(name))
+		if err == nil {
+			e.name = path.Base(name)
+			return e, nil
+		}
+		if !isNotImplemented(err) {
+			return nil, err
+		}
+	}
+	entries, err := fsys.c.ListEntriesIn(fsys.path(path.Dir(name)))
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Name() == path.Base(name) && !isSelfOrParent(e) {
+			return e, nil
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+func isSelfOrParent(e *Entry) bool {
+	return e.Kind() == EntryCurrentDir || e.Kind() == EntryParentDir ||
+		e.Name() == "." || e.Name() == ".."
+}
+
+// file is a downloaded file of an FS.
+type file struct {
+	*bytes.Reader
+	info *Entry
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *file) Close() error {
+	return nil
+}
+
+// dirFile is a directory of an FS. It is listed on the first call to ReadDir.
+type dirFile struct {
+	fsys    *FS
+	name    string
+	info    *Entry
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *dirFile) Close() error {
+	return nil
+}
+
+// ReadDir returns the next n entries of the directory, or all remaining ones
+// if n <= 0, as described for fs.ReadDirFile.
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		d.fsys.mu.Lock()
+		entries, err := d.fsys.readDir(d.name)
+		d.fsys.mu.Unlock()
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: err}
+		}
+		d.entries, d.listed = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,109 @@
+package ftp
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestInvalidPathsAreRejected(t *testing.T) {
+	fsys := (&Connection{}).FS("/pub")
+	for _, name := range []string{"/abs", "../up", "a//b", "dir/", ""} {
+		_, err := fsys.Open(name)
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) || pathErr.Path != name || !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("expected invalid path error for %q but got %v", name, err)
+		}
+	}
+}
+
+func TestFSNamesAreRelativeToRoot(t *testing.T) {
+	checkFSPath(t, "/pub", ".", "/pub")
+	checkFSPath(t, "/pub", "a/b.txt", "/pub/a/b.txt")
+	checkFSPath(t, "", ".", "")
+	checkFSPath(t, "", "a.txt", "a.txt")
+	checkFSPath(t, "/", "a.txt", "/a.txt")
+}
+
+func TestDirFileReadsEntriesInBatches(t *testing.T) {
+	d := &dirFile{listed: true, entries: []fs.DirEntry{
+		NewEntry("a", nil), NewEntry("b", nil), NewEntry("c", nil),
+	}}
+	entries, err := d.ReadDir(2)
+	if err != nil || len(entries) != 2 || entries[1].Name() != "b" {
+		t.Fatalf("unexpected first batch %v, %v", entries, err)
+	}
+	entries, err = d.ReadDir(2)
+	if err != nil || len(entries) != 1 || entries[0].Name() != "c" {
+		t.Fatalf("unexpected second batch %v, %v", entries, err)
+	}
+	if _, err = d.ReadDir(2); err != io.EOF {
+		t.Errorf("expected io.EOF but got %v", err)
+	}
+	if entries, err = d.ReadDir(-1); err != nil || len(entries) != 0 {
+		t.Errorf("expected no more entries but got %v, %v", entries, err)
+	}
+}
+
+func TestFSWithMLST(t *testing.T) {
+	checkFS(t, "MLSD", "MLST type*;size*;modify*;")
+}
+
+func TestFSWithLIST(t *testing.T) {
+	checkFS(t, "LIST")
+}
+
+// test helpers
+
+func checkFSPath(t *testing.T, root, name, expected string) {
+	p := (&Connection{}).FS(root).path(name)
+	if p != expected {
+		t.Errorf("expected %q in %q to be %q but was %q", name, root, expected, p)
+	}
+}
+
+// checkFS runs fstest.TestFS against a test server with the given features
+// and checks that directories were listed with the given command.
+func checkFS(t *testing.T, listCommand string, features ...string) {
+	server := startTestServer(t)
+	server.features = features
+	server.setFile("pub/readme.txt", []byte("hello"))
+	server.setFile("pub/empty.txt", nil)
+	server.setFile("pub/docs/a.txt", []byte("a"))
+	server.setFile("pub/docs/more/b.txt", []byte("bb"))
+	server.setFile("other.txt", []byte("not in the FS"))
+	c := server.connect()
+	defer c.Close()
+	if err := c.Login("user", "pass"); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := c.FS("/pub")
+	err := fstest.TestFS(fsys,
+		"readme.txt", "empty.txt", "docs/a.txt", "docs/more/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := fs.ReadFile(fsys, "docs/more/b.txt")
+	if err != nil || string(data) != "bb" {
+		t.Errorf("unexpected file content %q, %v", data, err)
+	}
+	if _, err := fs.Stat(fsys, "missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist but got %v", err)
+	}
+	if _, err := fs.ReadFile(fsys, "missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist but got %v", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	listed := false
+	for _, line := range server.commands {
+		listed = listed || strings.HasPrefix(line, listCommand+" ")
+	}
+	if !listed {
+		t.Errorf("expected directories to be listed with %s", listCommand)
+	}
+}
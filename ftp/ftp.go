@@ -5,22 +5,61 @@ package ftp
 
 import (
 	"bytes"
-	"errors"
-	"fmt"
+	"context"
+	"crypto/tls"
 	"io"
 	"io/ioutil"
 	"net"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Connection is the network connection to an FTP server. The Connect functions
 // return a *Connection which you have to Close after usage.
 type Connection struct {
+	*session
+	// ctx is used for all operations on this Connection, see WithContext.
+	ctx context.Context
+}
+
+// session is the state of the connection to the FTP server. It is shared by
+// all Connections that were created with WithContext.
+type session struct {
 	conn         net.Conn
 	logger       Logger
 	transferType transferType
+	// host is the name of the server that was used to connect. It is used
+	// to verify the server's certificate if the connection is upgraded to TLS.
+	host string
+	// tlsConfig is non-nil if data connections have to be secured using TLS.
+	tlsConfig      *tls.Config
+	config         Config
+	dataMode       dataMode
+	activeSettings ActiveModeSettings
+	// dataModeChosen is set once the caller picked a data mode. Only before
+	// that, EPSV is used just because the server lists it in its features.
+	dataModeChosen bool
+	// epsvAll is set after the server accepted EPSV ALL. From then on only
+	// EPSV may be used to open data connections.
+	epsvAll bool
+	// epsvUnsupported is set if the server rejected EPSV as unknown. PASV is
+	// used from then on.
+	epsvUnsupported      bool
+	passiveAddressPolicy PassiveAddressPolicy
+	// featureSet caches the server's reply to FEAT, see Features.
+	featureSet *Features
+	// utf8 is set once OPTS UTF8 ON succeeded. Before that, latin1 is set
+	// when the server sends names that are not valid UTF-8, see EnableUTF8.
+	utf8   bool
+	latin1 bool
+	// system caches the reply to SYST once systemKnown is set.
+	system      string
+	systemKnown bool
+	// streaming is set while a stream returned by Retrieve or Store is open.
+	// No commands may be sent until it is closed.
+	streaming bool
 }
 
 // Logger can be used to log the raw messages on the FTP control connection.
@@ -37,7 +76,15 @@ type Logger interface {
 // Connect establishes a connection to the given host on the given port.
 // The standard FTP port is 21.
 func Connect(host string, port uint16) (*Connection, error) {
-	return ConnectLogging(host, port, nil)
+	return ConnectLoggingContext(context.Background(), host, port, nil)
+}
+
+// ConnectContext establishes a connection to the given host on the given port.
+// The context is only used while connecting, cancelling it afterwards does not
+// affect the returned Connection. Use WithContext for that.
+// The standard FTP port is 21.
+func ConnectContext(ctx context.Context, host string, port uint16) (*Connection, error) {
+	return ConnectLoggingContext(ctx, host, port, nil)
 }
 
 // ConnectLogging establishes a connection to the given host on the given port.
@@ -45,25 +92,29 @@ func Connect(host string, port uint16) (*Connection, error) {
 // passed to the given Logger.
 // The standard FTP port is 21.
 func ConnectLogging(host string, port uint16, logger Logger) (*Connection, error) {
-	addr := fmt.Sprintf("%s:%d", host, port)
-	conn, err := net.Dial("tcp", addr)
-	if err != nil {
-		return nil, err
-	}
-	return newConnection(conn, logger)
+	return ConnectLoggingContext(context.Background(), host, port, logger)
+}
+
+// ConnectLoggingContext establishes a connection to the given host on the given
+// port, just like ConnectLogging. The context is only used while connecting,
+// cancelling it afterwards does not affect the returned Connection. Use
+// WithContext for that.
+// The standard FTP port is 21.
+func ConnectLoggingContext(ctx context.Context, host string, port uint16, logger Logger) (*Connection, error) {
+	return ConnectConfig(ctx, host, port, Config{Logger: logger})
 }
 
 // ConnectOn uses the given connection as an FTP control connection. This can be
 // used for setting connection parameters like time-outs.
 func ConnectOn(conn net.Conn) (*Connection, error) {
-	return newConnection(conn, nil)
+	return ConnectConfigOn(conn, Config{})
 }
 
 // ConnectLoggingOn uses the given connection as an FTP control connection. This
 // can be used for setting connection parameters like time-outs. It also sets
 // the logger.
 func ConnectLoggingOn(conn net.Conn, logger Logger) (*Connection, error) {
-	return newConnection(conn, logger)
+	return ConnectConfigOn(conn, Config{Logger: logger})
 }
 
 type transferType string
@@ -73,21 +124,40 @@ const (
 	transferBinary              = "binary"
 )
 
-func newConnection(conn net.Conn, logger Logger) (*Connection, error) {
-	c := &Connection{conn, logger, transferASCII}
-	resp, code, err := c.receive()
+// newConnection reads the server's greeting on the given control connection.
+// The context is only used for that, the returned Connection has a background
+// context.
+func newConnection(ctx context.Context, conn net.Conn, host string, config Config) (*Connection, error) {
+	s := &session{
+		conn:         conn,
+		logger:       config.Logger,
+		transferType: transferASCII,
+		host:         host,
+		config:       config,
+	}
+	resp, code, err := (&Connection{s, ctx}).receive()
 	if err != nil {
 		return nil, err
 	}
-	if code != serviceReadyForNewUser {
+	if code != CodeServiceReady {
 		return nil, errorMessage("connect", resp)
 	}
-	return c, nil
+	return &Connection{s, context.Background()}, nil
+}
+
+func hostOf(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return ""
+	}
+	return host
 }
 
 func errorMessage(command string, response []byte) error {
-	return errors.New("FTP server responded to " + command +
-		" with error: " + string(response))
+	return newError(command, response)
 }
 
 // Close closes the underlying TCP connection to the FTP server. Call this
@@ -98,8 +168,19 @@ func (c *Connection) Close() {
 }
 
 func (c *Connection) send(words ...string) error {
+	if err := c.context().Err(); err != nil {
+		return err
+	}
+	if c.streaming {
+		return ErrTransferInProgress
+	}
 	msg := strings.Join(words, " ") + "\r\n"
+	c.setControlDeadline()
+	stop := c.interruptOnCancel(expire(c.conn))
 	_, err := c.conn.Write([]byte(msg))
+	if stop() {
+		err = c.ctx.Err()
+	}
 	if c.logger != nil {
 		c.logger.SentFTP([]byte(msg), err)
 	}
@@ -114,8 +195,16 @@ func (c *Connection) sendWithoutEmptyString(cmd, arg string) error {
 }
 
 // if the returned error is not nil then the response and the code are not meaningful
-func (c *Connection) receive() (response []byte, code responseCode, e error) {
+func (c *Connection) receive() (response []byte, code ReplyCode, e error) {
+	if err := c.context().Err(); err != nil {
+		return nil, 0, err
+	}
+	c.setControlDeadline()
+	stop := c.interruptOnCancel(expire(c.conn))
 	msg, err := readResponse(c.conn)
+	if stop() {
+		err = c.ctx.Err()
+	}
 	if c.logger != nil {
 		c.logger.ReceivedFTP(msg, err)
 	}
@@ -190,11 +279,20 @@ func lastLineEndsInSameCodeAsFirstLine(msg []byte) bool {
 	return last[:4] == codePlusSpace
 }
 
-func extractCode(msg []byte) responseCode {
-	if len(msg) <= 3 {
-		return responseCode(msg)
+// extractCode returns the reply code at the start of msg or 0 if there is no
+// valid code.
+func extractCode(msg []byte) ReplyCode {
+	if len(msg) < 3 {
+		return 0
 	}
-	return responseCode(msg[:3])
+	code := 0
+	for _, digit := range msg[:3] {
+		if digit < '0' || digit > '9' {
+			return 0
+		}
+		code = 10*code + int(digit-'0')
+	}
+	return ReplyCode(code)
 }
 
 // Login sends the given user and, if required,  password to the FTP server.
@@ -202,6 +300,7 @@ func extractCode(msg []byte) responseCode {
 // password will be sent. In this case just pass an empty string for the password.
 // The FTP commands this sends are USER and (optionally) PASS.
 func (c *Connection) Login(user, password string) error {
+	c.featureSet = nil
 	err := c.send("USER", user)
 	if err != nil {
 		return err
@@ -210,21 +309,21 @@ func (c *Connection) Login(user, password string) error {
 	if err != nil {
 		return err
 	}
-	if code == userLoggedIn_Proceed {
+	if code == CodeUserLoggedIn {
 		return nil
 	}
-	if code == userNameOK_NeedPassword {
-		return c.execute(userLoggedIn_Proceed, "PASS", password)
+	if code == CodeUserNameOK {
+		return c.execute(CodeUserLoggedIn, "PASS", password)
 	}
 	return errorMessage("USER", resp)
 }
 
-func (c *Connection) execute(success responseCode, args ...string) error {
+func (c *Connection) execute(success ReplyCode, args ...string) error {
 	_, err := c.executeGetResponse(success, args...)
 	return err
 }
 
-func (c *Connection) executeGetResponse(expectedCode responseCode, args ...string) ([]byte, error) {
+func (c *Connection) executeGetResponse(expectedCode ReplyCode, args ...string) ([]byte, error) {
 	err := c.send(args...)
 	if err != nil {
 		return nil, err
@@ -244,53 +343,56 @@ func (c *Connection) executeGetResponse(expectedCode responseCode, args ...strin
 // needed.
 // The FTP command this sends is CWD
 func (c *Connection) ChangeWorkingDirTo(path string) error {
-	return c.execute(fileActionCompleted, "CWD", path)
+	return c.execute(CodeFileActionOK, "CWD", c.encodeName(path))
 }
 
 // ChangeDirUp moves the current working directory up one folder (like
 // a 'cd ..' in the console).
 // The FTP command this sends is CDUP.
 func (c *Connection) ChangeDirUp() error {
-	return c.execute(commandOk, "CDUP")
+	return c.execute(CodeCommandOK, "CDUP")
 }
 
 // StructureMount mounts the given path. The path argument is sent as is so
 // make sure to surround the string with quotes if needed.
 // The FTP command this sends is SMNT.
 func (c *Connection) StructureMount(path string) error {
-	return c.execute(fileActionCompleted, "SMNT", path)
+	return c.execute(CodeFileActionOK, "SMNT", c.encodeName(path))
 }
 
 // Reinitialize closes the current session and starts over again. You may want
 // to Login again after this command.
 // The FTP command this sends is REIN.
 func (c *Connection) Reinitialize() error {
-	return c.execute(serviceReadyForNewUser, "REIN")
+	c.featureSet = nil
+	c.utf8 = false
+	c.latin1 = false
+	return c.execute(CodeServiceReady, "REIN")
 }
 
 // Quit closes the current FTP session. It does not however close the underlying
 // TCP connection. For that you need to call Close once you are done.
 // The FTP command this sends is QUIT.
 func (c *Connection) Quit() error {
-	return c.execute(serviceClosingControlConnection, "QUIT")
+	return c.execute(CodeServiceClosingControlConnection, "QUIT")
 }
 
 // RenameFromTo changes the name of a file (from) to the new name (to). The paths
 // are sent as is so make sure to surround the strings with quotes if needed.
 // The FTP commands this sends are RNFR and RNTO.
 func (c *Connection) RenameFromTo(from, to string) error {
-	err := c.execute(fileActionPending, "RNFR", from)
+	err := c.execute(CodeFileActionPending, "RNFR", c.encodeName(from))
 	if err != nil {
 		return err
 	}
-	return c.execute(fileActionCompleted, "RNTO", to)
+	return c.execute(CodeFileActionOK, "RNTO", c.encodeName(to))
 }
 
 // Delete erases the given path from the FTP server. The path argument is sent as
 // is so make sure to surround the string with quotes if needed.
 // The FTP command this sends is DELE.
 func (c *Connection) Delete(path string) error {
-	return c.execute(fileActionCompleted, "DELE", path)
+	return c.execute(CodeFileActionOK, "DELE", c.encodeName(path))
 }
 
 // MakeDirectory creates a new directory under the given path. Since this path
@@ -300,25 +402,25 @@ func (c *Connection) Delete(path string) error {
 // to surround the string with quotes if needed.
 // The FTP command this sends is MKD.
 func (c *Connection) MakeDirectory(path string) (string, error) {
-	resp, err := c.executeGetResponse(pathNameCreated, "MKD", path)
+	resp, err := c.executeGetResponse(CodePathCreated, "MKD", c.encodeName(path))
 	if err != nil {
 		return "", err
 	}
-	return getPathFromResponse(resp)
+	return c.pathFromResponse(resp)
 }
 
 // RemoveDirectory erases the directory under the given path. The path is sent
 // as is so make sure to surround the string with quotes if needed.
 // The FTP command this sends is RMD.
 func (c *Connection) RemoveDirectory(path string) error {
-	return c.execute(fileActionCompleted, "RMD", path)
+	return c.execute(CodeFileActionOK, "RMD", c.encodeName(path))
 }
 
 // NoOperation sends a message to the FTP server and makes sure the repsonse is
 // OK. This can be used as a kind of ping to see if the server is still responding.
 // The FTP command this sends is NOOP.
 func (c *Connection) NoOperation() error {
-	return c.execute(commandOk, "NOOP")
+	return c.execute(CodeCommandOK, "NOOP")
 }
 
 // Help returns a human readable help message from the FTP server. This message
@@ -340,7 +442,7 @@ func (c *Connection) HelpAbout(topic string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if code == systemStatusOrHelpReply || code == helpMessage {
+	if code == CodeSystemStatus || code == CodeHelpMessage {
 		return removeControlSymbols(resp), nil
 	}
 	return "", errorMessage("HELP", resp)
@@ -371,7 +473,7 @@ func (c *Connection) Status() (StatusType, string, error) {
 // any control codes.
 // The FTP command this sends is STAT.
 func (c *Connection) StatusOf(path string) (StatusType, string, error) {
-	err := c.sendWithoutEmptyString("STAT", path)
+	err := c.sendWithoutEmptyString("STAT", c.encodeName(path))
 	if err != nil {
 		return "", "", err
 	}
@@ -385,14 +487,14 @@ func (c *Connection) StatusOf(path string) (StatusType, string, error) {
 	return "", "", errorMessage("STAT", resp)
 }
 
-func statusTypeOfCode(code responseCode) (typ StatusType, ok bool) {
-	if code == systemStatusOrHelpReply {
+func statusTypeOfCode(code ReplyCode) (typ StatusType, ok bool) {
+	if code == CodeSystemStatus {
 		return GeneralStatus, true
 	}
-	if code == directoryStatus {
+	if code == CodeDirectoryStatus {
 		return DirectoryStatus, true
 	}
-	if code == fileStatus {
+	if code == CodeFileStatus {
 		return FileStatus, true
 	}
 	return "", false
@@ -411,41 +513,68 @@ const (
 // include the operating system and other information.
 // The FTP command this sends is SYST.
 func (c *Connection) System() (string, error) {
-	resp, err := c.executeGetResponse(systemName, "SYST")
+	resp, err := c.executeGetResponse(CodeSystemType, "SYST")
 	if err != nil {
 		return "", err
 	}
 	return removeControlSymbols(resp), nil
 }
 
+// Size returns the size of the file at the given path in bytes. The size is
+// requested for binary transfers so it is the number of bytes that Download
+// would write.
+// The FTP commands this sends are TYPE I and SIZE.
+func (c *Connection) Size(path string) (int64, error) {
+	err := c.setBinaryTransfer()
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.executeGetResponse(CodeFileStatus, "SIZE", c.encodeName(path))
+	if err != nil {
+		return 0, err
+	}
+	return getSizeFromResponse(resp)
+}
+
+func getSizeFromResponse(resp []byte) (int64, error) {
+	lines := replyLines(resp)
+	size, err := strconv.ParseInt(strings.TrimSpace(lines[len(lines)-1]), 10, 64)
+	if err != nil {
+		return 0, errorMessage("size extraction", resp)
+	}
+	return size, nil
+}
+
 // PrintWorkingDirectory returns the current working directory.
 // The FTP command this sends is PWD.
 func (c *Connection) PrintWorkingDirectory() (string, error) {
-	resp, err := c.executeGetResponse(pathNameCreated, "PWD")
+	resp, err := c.executeGetResponse(CodePathCreated, "PWD")
 	if err != nil {
 		return "", err
 	}
-	return getPathFromResponse(resp)
+	return c.pathFromResponse(resp)
 }
 
 // Abort aborts the currently running file transaction (if any). If no file
 // transfer is being executed or if shutting down the data connection was
-// successful, the returned error will be nil.
+// successful, the returned error will be nil. The server's reply that the
+// transfer failed, 425 or 426, is read before the reply to ABOR.
 // The FTP command this sends is ABOR.
 func (c *Connection) Abort() error {
 	resp, code, err := c.sendAndReceive("ABOR")
 	if err != nil {
 		return err
 	}
-	if code == noTransferInProgress || code == closingDataConnection {
+	if code == CodeDataConnectionOpen || code == CodeClosingDataConnection {
 		return nil
 	}
-	if code == connectionClosed_TransferAborter {
+	if code == CodeConnectionClosedTransferAborted ||
+		code == CodeCannotOpenDataConnection {
 		resp, code, err = c.receive()
 		if err != nil {
 			return err
 		}
-		if code == closingDataConnection {
+		if code == CodeDataConnectionOpen || code == CodeClosingDataConnection {
 			return nil
 		}
 		return errorMessage("ABOR", resp)
@@ -453,16 +582,24 @@ func (c *Connection) Abort() error {
 	return errorMessage("ABOR", resp)
 }
 
-func (c *Connection) sendAndReceive(words ...string) ([]byte, responseCode, error) {
+func (c *Connection) sendAndReceive(words ...string) ([]byte, ReplyCode, error) {
 	err := c.send(words...)
 	if err != nil {
-		return nil, "", err
+		return nil, 0, err
 	}
 	return c.receive()
 }
 
 var pathMatcher = regexp.MustCompile("[0-9][0-9][0-9][ |-]\"(.+)\".*\r\n")
 
+func (c *Connection) pathFromResponse(resp []byte) (string, error) {
+	path, err := getPathFromResponse(resp)
+	if err != nil {
+		return "", err
+	}
+	return c.decodeName(path), nil
+}
+
 func getPathFromResponse(resp []byte) (string, error) {
 	if !pathMatcher.Match(resp) {
 		return "", errorMessage("path extraction", resp)
@@ -484,7 +621,7 @@ func (c *Connection) setTransferTypeTo(t transferType, symbol string) error {
 	if c.transferType == t {
 		return nil
 	}
-	err := c.execute(commandOk, "TYPE", symbol)
+	err := c.execute(CodeCommandOK, "TYPE", symbol)
 	if err == nil {
 		c.transferType = t
 	}
@@ -494,6 +631,8 @@ func (c *Connection) setTransferTypeTo(t transferType, symbol string) error {
 // ListFiles returns detailed information about the current working directory.
 // The result does not contain any control codes. The format of the result depends
 // on the implementation of the server so no automatic parsing happens here.
+// Use ListEntries for a machine-readable listing or ParseListing to parse the
+// result.
 // The FTP command this sends is LIST.
 func (c *Connection) ListFiles() (string, error) {
 	return c.ListFilesIn("")
@@ -503,6 +642,8 @@ func (c *Connection) ListFiles() (string, error) {
 // The result does not contain any control codes. The format of the result depends
 // on the implementation of the server so no automatic parsing happens here.
 // The path is sent as is so make sure to surround the string with quotes if needed.
+// Use ListEntriesIn for a machine-readable listing or ParseListing to parse the
+// result.
 // The FTP command this sends is LIST.
 func (c *Connection) ListFilesIn(path string) (string, error) {
 	return c.readListCommandData("LIST", path)
@@ -539,38 +680,135 @@ func (c *Connection) readListCommandData(cmd, path string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	dataConn, err := c.enterPassiveMode()
+	var data []byte
+	err = c.transfer(cmd, path, func(dataConn net.Conn) error {
+		data, err = ioutil.ReadAll(dataConn)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
-	defer dataConn.Close()
-	err = c.sendWithoutEmptyString(cmd, path)
+	return c.decodeName(string(data)), nil
+}
+
+// transfer starts a transfer with the given command and argument, calls the
+// given function with the data connection and finishes the transfer. If the
+// context is cancelled while data is transferred, the transfer is aborted and
+// the context's error is returned.
+func (c *Connection) transfer(cmd, arg string, transferData func(net.Conn) error) error {
+	_, err := c.transferGetReply(cmd, arg, 0, transferData)
+	return err
+}
+
+// transferGetReply works like transfer and returns the server's final reply.
+// If offset is not 0, the transfer is restarted at that offset, see
+// startTransfer.
+func (c *Connection) transferGetReply(cmd, arg string, offset int64, transferData func(net.Conn) error) (*Reply, error) {
+	dataConn, err := c.startTransfer(cmd, arg, offset)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	stop := c.interruptOnCancel(func() { dataConn.Close() })
+	err = transferData(dataConn)
+	if stop() {
+		return nil, c.abortTransfer(dataConn)
+	}
+	if err != nil {
+		dataConn.Close()
+		return nil, err
+	}
+	return c.finishTransfer(cmd, dataConn)
+}
+
+// startTransfer prepares a data connection in the current data mode, sends the
+// command with the (optional) argument and establishes the data connection once
+// the server accepted the command. After all data is transferred, call
+// finishTransfer.
+// If offset is not 0, REST is sent right before the command so the server
+// starts the transfer at that byte offset of the file.
+// The argument is a path, it is converted to the server's character set.
+func (c *Connection) startTransfer(cmd, arg string, offset int64) (net.Conn, error) {
+	data, err := c.prepareDataConnection()
+	if err != nil {
+		return nil, err
+	}
+	if offset != 0 {
+		err = c.execute(CodeFileActionPending, "REST", strconv.FormatInt(offset, 10))
+		if err != nil {
+			data.close()
+			return nil, err
+		}
+	}
+	err = c.sendWithoutEmptyString(cmd, c.encodeName(arg))
+	if err != nil {
+		data.close()
+		return nil, err
 	}
 	resp, code, err := c.receive()
 	if err != nil {
-		return "", err
+		data.close()
+		return nil, err
 	}
 	if !code.ok() {
-		return "", errorMessage(cmd, resp)
+		data.close()
+		return nil, errorMessage(commandOf(cmd), resp)
 	}
-	data, err := ioutil.ReadAll(dataConn)
+	conn, err := data.connect(c.context())
 	if err != nil {
-		return "", err
+		return nil, c.failTransfer(err)
+	}
+	if c.config.DataTimeout > 0 {
+		conn = idleTimeoutConn{conn, c.config.DataTimeout}
 	}
-	resp, code, err = c.receive()
+	secured, err := c.secureDataConnection(conn)
 	if err != nil {
-		return "", err
+		conn.Close()
+		return nil, c.failTransfer(err)
+	}
+	return secured, nil
+}
+
+// failTransfer is called if the data connection could not be established after
+// the server accepted the transfer command. The server's final reply, usually
+// 425 or 426, is read and dropped so the control connection stays in step. The
+// given error is returned because it tells why the data connection failed.
+// If the context is done, the transfer is aborted instead and the context's
+// error is returned.
+func (c *Connection) failTransfer(err error) error {
+	if c.context().Err() != nil {
+		return c.abort()
+	}
+	c.conn.SetDeadline(time.Now().Add(abortTimeout))
+	c.receive()
+	c.conn.SetDeadline(time.Time{})
+	return err
+}
+
+// finishTransfer closes the data connection and reads the server's final
+// reply to the transfer command.
+func (c *Connection) finishTransfer(cmd string, dataConn net.Conn) (*Reply, error) {
+	err := dataConn.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp, code, err := c.receive()
+	if err != nil {
+		return nil, err
 	}
 	if !code.ok() {
-		return "", errorMessage(cmd, resp)
+		return nil, errorMessage(commandOf(cmd), resp)
 	}
-	return string(data), nil
+	return newReply(resp), nil
 }
 
 func (c *Connection) enterPassiveMode() (net.Conn, error) {
-	resp, err := c.executeGetResponse(enteringPassiveMode, "PASV")
+	if c.useExtendedPassiveMode() {
+		conn, supported, err := c.enterExtendedPassiveMode()
+		if supported || err != nil {
+			return conn, err
+		}
+	}
+	resp, err := c.executeGetResponse(CodeEnteringPassiveMode, "PASV")
 	if err != nil {
 		return nil, err
 	}
@@ -578,7 +816,11 @@ func (c *Connection) enterPassiveMode() (net.Conn, error) {
 	if err != nil {
 		return nil, err
 	}
-	return net.Dial("tcp", addr)
+	addr, err = c.applyPassiveAddressPolicy(addr)
+	if err != nil {
+		return nil, err
+	}
+	return c.dialData(addr)
 }
 
 var addrMatcher = regexp.MustCompile(
@@ -598,115 +840,49 @@ func getAddressOfPasvResponse(msg []byte) (string, error) {
 
 // Download writes the contents of the file at the given path into the given
 // writer.
-// It reads the file as binary data from the FTP server in passive mode.
+// It reads the file as binary data from the FTP server.
 // The FTP command this sends is RETR.
 func (c *Connection) Download(path string, dest io.Writer) error {
-	err := c.setBinaryTransfer()
-	if err != nil {
-		return err
-	}
-	dataConn, err := c.enterPassiveMode()
-	if err != nil {
-		return err
-	}
-	err = c.send("RETR", path)
-	if err != nil {
-		dataConn.Close()
-		return err
-	}
-	resp, code, err := c.receive()
-	if err != nil {
-		dataConn.Close()
-		return err
-	}
-	if !code.ok() {
-		dataConn.Close()
-		return errorMessage("RETR", resp)
-	}
-	_, err = io.Copy(dest, dataConn)
-	if err != nil {
-		dataConn.Close()
-		return err
-	}
-	err = dataConn.Close()
-	if err != nil {
-		return err
-	}
-	resp, code, err = c.receive()
-	if err != nil {
-		return err
-	}
-	if !code.ok() {
-		return errorMessage("RETR", resp)
-	}
-	return nil
+	return c.DownloadFrom(path, 0, dest)
 }
 
 // Upload writes the contents of the given source to a file at the given path
 // on the server. If the file was there before, it is overwritten. Otherwise a
 // new file is created.
-// The file is written as binary in passive mode.
+// The file is written as binary data.
 // The FTP command this sends is STOR.
 func (c *Connection) Upload(source io.Reader, path string) error {
-	return c.upload("STOR", path, source)
+	return c.upload("STOR", path, 0, source)
 }
 
 // UploadUnique writes the contents of the given source to a file at the given
 // path on the server. If the file was there before, it is overwritten.
 // Otherwise a new file is created.
-// It file is written as binary in passive mode.
+// It file is written as binary data.
 // The FTP command this sends is STOU.
 func (c *Connection) UploadUnique(source io.Reader) error {
-	return c.upload("STOU", "", source)
+	return c.upload("STOU", "", 0, source)
 }
 
 // Append appends the contents of the given source to a file at the given path
 // on the server. If the file was there before, it is overwritten. Otherwise a
 // new file is created.
-// It file is written as binary in passive mode.
+// It file is written as binary data.
 // The FTP command this sends is APPE.
 func (c *Connection) Append(source io.Reader, path string) error {
-	return c.upload("APPE", path, source)
+	return c.upload("APPE", path, 0, source)
 }
 
-func (c *Connection) upload(cmd, path string, source io.Reader) error {
+// upload sends the source with the given command. If offset is not 0, the
+// server writes it to the file starting at that offset, see startTransfer.
+func (c *Connection) upload(cmd, path string, offset int64, source io.Reader) error {
 	err := c.setBinaryTransfer()
 	if err != nil {
 		return err
 	}
-	dataConn, err := c.enterPassiveMode()
-	if err != nil {
-		return err
-	}
-	err = c.sendWithoutEmptyString(cmd, path)
-	if err != nil {
-		dataConn.Close()
-		return err
-	}
-	resp, code, err := c.receive()
-	if err != nil {
-		dataConn.Close()
-		return err
-	}
-	if !code.ok() {
-		dataConn.Close()
-		return errorMessage(cmd, resp)
-	}
-	_, err = io.Copy(dataConn, source)
-	if err != nil {
-		dataConn.Close()
-		return err
-	}
-	err = dataConn.Close()
-	if err != nil {
-		return err
-	}
-	resp, code, err = c.receive()
-	if err != nil {
+	_, err = c.transferGetReply(cmd, path, offset, func(dataConn net.Conn) error {
+		_, err := io.Copy(dataConn, source)
 		return err
-	}
-	if !code.ok() {
-		return errorMessage(cmd, resp)
-	}
-	return nil
+	})
+	return err
 }
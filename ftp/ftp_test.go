@@ -1,6 +1,20 @@
 package ftp
 
-import "testing"
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
 
 func TestCompleteResponseHasCodeThenSpaceAndNewLine(t *testing.T) {
 	checkCompleteResponse(t, "123 optional text\r\n")
@@ -47,6 +61,14 @@ func TestPathComesInQuotes(t *testing.T) {
 	checkExtractedPath(t, "257-\"path\"\r\n257 \r\n", "path")
 }
 
+func TestSizeIsParsedFromResponse(t *testing.T) {
+	checkSize(t, "213 0\r\n", 0)
+	checkSize(t, "213 12345678901\r\n", 12345678901)
+	if _, err := getSizeFromResponse([]byte("213 unknown\r\n")); err == nil {
+		t.Error("expected error for invalid size")
+	}
+}
+
 // test helpers
 
 func checkCompleteResponse(t *testing.T, msg string) {
@@ -89,3 +111,422 @@ func checkExtractedPath(t *testing.T, resp, expected string) {
 		t.Errorf("expected path '%v' but got '%v'", expected, path)
 	}
 }
+
+func checkSize(t *testing.T, resp string, expected int64) {
+	size, err := getSizeFromResponse([]byte(resp))
+	if err != nil {
+		t.Errorf("got error %v", err.Error())
+	}
+	if size != expected {
+		t.Errorf("expected size %v but was %v", expected, size)
+	}
+}
+
+// testServer is a minimal FTP server on the loopback interface. It supports
+// passive mode, explicit FTPS, transfers and listings of the files in its file
+// map. Directories are implied by the paths of the files, the working
+// directory is always the root.
+type testServer struct {
+	t        *testing.T
+	listener net.Listener
+	// tls, if set, allows AUTH TLS with this configuration.
+	tls *tls.Config
+	// implicitTLS makes the server start TLS right after accepting a control
+	// connection, using the tls configuration.
+	implicitTLS bool
+	// rejectDataTLS makes protected transfers fail with 425 instead of doing
+	// the TLS handshake on the data connection.
+	rejectDataTLS bool
+	// activeDelay is how long to wait before connecting to the client in
+	// active mode.
+	activeDelay time.Duration
+	// activeFrom, if set, is the local IP address for active data connections.
+	activeFrom string
+	// features are sent in reply to FEAT. MLST and MLSD are only implemented
+	// if they contain MLST.
+	features []string
+
+	accepting sync.Once
+	mu        sync.Mutex
+	files     map[string][]byte
+	commands  []string
+}
+
+// startTestServer listens on a free port. Connections are only accepted once
+// port or connect is called, so the settings can be changed until then.
+func startTestServer(t *testing.T) *testServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{t: t, listener: listener, files: make(map[string][]byte)}
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+// port starts accepting connections and returns the port of the server.
+func (s *testServer) port() uint16 {
+	s.accepting.Do(func() {
+		go func() {
+			for {
+				conn, err := s.listener.Accept()
+				if err != nil {
+					return
+				}
+				go s.serve(conn)
+			}
+		}()
+	})
+	return uint16(s.listener.Addr().(*net.TCPAddr).Port)
+}
+
+// connect returns a Connection to the server that is not logged in yet.
+func (s *testServer) connect() *Connection {
+	c, err := Connect("127.0.0.1", s.port())
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return c
+}
+
+func (s *testServer) file(name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[testPath(name)]
+	return data, ok
+}
+
+func (s *testServer) setFile(name string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[testPath(name)] = data
+}
+
+// testPath returns the key of a path in the file map of a testServer.
+func testPath(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+// testEntry is a file or directory of a testServer.
+type testEntry struct {
+	name string
+	dir  bool
+	size int64
+}
+
+// stat returns the file or directory at the given path.
+func (s *testServer) stat(name string) (testEntry, bool) {
+	name = testPath(name)
+	if data, ok := s.file(name); ok {
+		return testEntry{name: path.Base(name), size: int64(len(data))}, true
+	}
+	_, ok := s.list(name)
+	return testEntry{name: path.Base(name), dir: true}, ok
+}
+
+// list returns the files and directories in the given directory, sorted by
+// name. It reports false if there is no such directory.
+func (s *testServer) list(dir string) ([]testEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prefix := testPath(dir) + "/"
+	if prefix == "/" {
+		prefix = ""
+	}
+	found := make(map[string]testEntry)
+	for key, data := range s.files {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		name := key[len(prefix):]
+		if i := strings.IndexByte(name, '/'); i != -1 {
+			found[name[:i]] = testEntry{name: name[:i], dir: true}
+		} else {
+			found[name] = testEntry{name: name, size: int64(len(data))}
+		}
+	}
+	if len(found) == 0 && prefix != "" {
+		return nil, false
+	}
+	var entries []testEntry
+	for _, e := range found {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries, true
+}
+
+// mlsxLine formats e as in the replies to MLST and MLSD.
+func (e testEntry) mlsxLine() string {
+	if e.dir {
+		return "type=dir;modify=20200102030405; " + e.name
+	}
+	return fmt.Sprintf("type=file;size=%d;modify=20200102030405; %s", e.size, e.name)
+}
+
+// listLine formats e like "ls -l" for the reply to LIST.
+func (e testEntry) listLine() string {
+	if e.dir {
+		return "drwxr-xr-x 1 owner group 0 Jan  2  2020 " + e.name
+	}
+	return fmt.Sprintf("-rw-r--r-- 1 owner group %d Jan  2  2020 %s", e.size, e.name)
+}
+
+func (s *testServer) supportsMLST() bool {
+	for _, f := range s.features {
+		if strings.HasPrefix(f, "MLST") {
+			return true
+		}
+	}
+	return false
+}
+
+// testSession is the state of one control connection of a testServer.
+type testSession struct {
+	server  *testServer
+	conn    net.Conn
+	reader  *bufio.Reader
+	passive net.Listener
+	// active is the address that the client sent with PORT or EPRT.
+	active  string
+	protect bool
+}
+
+func (s *testServer) serve(conn net.Conn) {
+	defer conn.Close()
+	if s.implicitTLS {
+		conn = tls.Server(conn, s.tls)
+	}
+	x := &testSession{server: s, conn: conn, reader: bufio.NewReader(conn)}
+	defer x.closeDataPort()
+	x.reply("220 ready")
+	for {
+		line, err := x.reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSuffix(line, "\r\n")
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+		cmd, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i != -1 {
+			cmd, arg = line[:i], line[i+1:]
+		}
+		if !x.handle(strings.ToUpper(cmd), arg) {
+			return
+		}
+	}
+}
+
+// handle executes a command and reports whether the session continues.
+func (x *testSession) handle(cmd, arg string) bool {
+	switch cmd {
+	case "USER":
+		x.reply("331 password please")
+	case "PASS":
+		x.reply("230 logged in")
+	case "TYPE", "NOOP", "PBSZ":
+		x.reply("200 ok")
+	case "PROT":
+		x.protect = arg == "P"
+		x.reply("200 ok")
+	case "AUTH":
+		if x.server.tls == nil {
+			x.reply("502 no TLS")
+			return true
+		}
+		x.reply("234 start TLS")
+		tlsConn := tls.Server(x.conn, x.server.tls)
+		if tlsConn.Handshake() != nil {
+			return false
+		}
+		x.conn = tlsConn
+		x.reader = bufio.NewReader(tlsConn)
+	case "PASV":
+		x.enterPassiveMode()
+	case "PORT", "EPRT":
+		x.closeDataPort()
+		x.active = activeAddress(cmd, arg)
+		if x.active == "" {
+			x.reply("501 invalid address")
+			return true
+		}
+		x.reply("200 ok")
+	case "SYST":
+		x.reply("215 UNIX Type: L8")
+	case "FEAT":
+		if len(x.server.features) == 0 {
+			x.reply("211 no features")
+			return true
+		}
+		x.reply("211-Features:\r\n %s\r\n211 End",
+			strings.Join(x.server.features, "\r\n "))
+	case "MLST":
+		e, ok := x.server.stat(arg)
+		if !x.server.supportsMLST() {
+			x.reply("502 not implemented")
+		} else if !ok {
+			x.reply("550 not found")
+		} else {
+			x.reply("250-Listing %s\r\n %s\r\n250 End", arg, e.mlsxLine())
+		}
+	case "MLSD", "LIST":
+		entries, ok := x.server.list(arg)
+		if cmd == "MLSD" && !x.server.supportsMLST() {
+			x.closeDataPort()
+			x.reply("502 not implemented")
+			return true
+		}
+		if !ok {
+			x.closeDataPort()
+			x.reply("550 directory not found")
+			return true
+		}
+		x.transfer(func(conn net.Conn) error {
+			for _, e := range entries {
+				line := e.listLine()
+				if cmd == "MLSD" {
+					line = e.mlsxLine()
+				}
+				if _, err := io.WriteString(conn, line+"\r\n"); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case "RETR":
+		data, ok := x.server.file(arg)
+		if !ok {
+			x.closeDataPort()
+			x.reply("550 file not found")
+			return true
+		}
+		x.transfer(func(conn net.Conn) error {
+			_, err := conn.Write(data)
+			return err
+		})
+	case "STOR":
+		x.transfer(func(conn net.Conn) error {
+			data, err := ioutil.ReadAll(conn)
+			if err == nil {
+				x.server.setFile(arg, data)
+			}
+			return err
+		})
+	case "ABOR":
+		// Transfers are finished before the next command is read, so there is
+		// nothing to abort. Wait a moment so this reply cannot arrive together
+		// with the final reply of the aborted transfer.
+		time.Sleep(20 * time.Millisecond)
+		x.reply("225 no transfer to abort")
+	case "QUIT":
+		x.reply("221 bye")
+		return false
+	default:
+		x.reply("502 not implemented")
+	}
+	return true
+}
+
+func (x *testSession) reply(format string, args ...interface{}) {
+	fmt.Fprintf(x.conn, format+"\r\n", args...)
+}
+
+func (x *testSession) enterPassiveMode() {
+	x.closeDataPort()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		x.reply("425 cannot listen")
+		return
+	}
+	x.passive = listener
+	port := listener.Addr().(*net.TCPAddr).Port
+	x.reply("227 Entering Passive Mode (127,0,0,1,%d,%d).", port/256, port%256)
+}
+
+func (x *testSession) closeDataPort() {
+	if x.passive != nil {
+		x.passive.Close()
+		x.passive = nil
+	}
+	x.active = ""
+}
+
+// activeAddress returns the address of a PORT or EPRT argument or "" if it is
+// invalid.
+func activeAddress(cmd, arg string) string {
+	if cmd == "EPRT" {
+		parts := strings.Split(arg, "|")
+		if len(parts) != 5 {
+			return ""
+		}
+		return net.JoinHostPort(parts[2], parts[3])
+	}
+	var h [4]int
+	var p1, p2 int
+	_, err := fmt.Sscanf(arg, "%d,%d,%d,%d,%d,%d", &h[0], &h[1], &h[2], &h[3], &p1, &p2)
+	if err != nil {
+		return ""
+	}
+	ip := fmt.Sprintf("%d.%d.%d.%d", h[0], h[1], h[2], h[3])
+	return net.JoinHostPort(ip, strconv.Itoa(p1*256+p2))
+}
+
+// openDataConnection accepts the client's connection in passive mode or
+// connects to the client in active mode.
+func (x *testSession) openDataConnection() (net.Conn, error) {
+	defer x.closeDataPort()
+	if x.passive != nil {
+		return x.passive.Accept()
+	}
+	time.Sleep(x.server.activeDelay)
+	var dialer net.Dialer
+	if x.server.activeFrom != "" {
+		dialer.LocalAddr = &net.TCPAddr{IP: net.ParseIP(x.server.activeFrom)}
+	}
+	return dialer.Dial("tcp", x.active)
+}
+
+// transfer opens the data connection and calls fn with it. The final reply is
+// only sent after the client closed the data connection, so it cannot arrive
+// together with the preliminary reply.
+func (x *testSession) transfer(fn func(net.Conn) error) {
+	if x.passive == nil && x.active == "" {
+		x.reply("425 use PASV or PORT first")
+		return
+	}
+	x.reply("150 opening data connection")
+	conn, err := x.openDataConnection()
+	if err != nil {
+		x.reply("425 no data connection")
+		return
+	}
+	defer conn.Close()
+	if x.protect && x.server.rejectDataTLS {
+		// Wait for the client hello so the reply cannot arrive together with
+		// the preliminary reply.
+		conn.Read(make([]byte, 1))
+		conn.Close()
+		x.reply("425 no TLS for you")
+		return
+	}
+	if x.protect {
+		tlsConn := tls.Server(conn, x.server.tls)
+		if err := tlsConn.Handshake(); err != nil {
+			x.reply("522 TLS handshake failed")
+			return
+		}
+		conn = tlsConn
+	}
+	err = fn(conn)
+	if closer, ok := conn.(interface{ CloseWrite() error }); ok {
+		closer.CloseWrite()
+	}
+	io.Copy(ioutil.Discard, conn)
+	if err != nil {
+		x.reply("426 transfer failed: %v", err)
+		return
+	}
+	x.reply("226 transfer complete")
+}
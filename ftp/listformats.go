@@ -0,0 +1,263 @@
+package ftp
+
+import (
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// eplfListingParser parses the Easily Parsed LIST Format, e.g.
+//
+//	+i8388621.48594,m825718503,r,s280,	djb.html
+//
+// The facts before the tab are / for directories, r for files that can be
+// retrieved, s for the size, m for the modification time in Unix seconds, i
+// for a unique ID and up for the Unix permissions in octal.
+type eplfListingParser struct{}
+
+func (eplfListingParser) Name() string { return "eplf" }
+
+func (eplfListingParser) MatchesSystem(string) bool { return false }
+
+func (eplfListingParser) ParseLine(line string) (*Entry, bool) {
+	tab := strings.IndexByte(line, '\t')
+	if !strings.HasPrefix(line, "+") || tab == -1 {
+		return nil, false
+	}
+	facts := map[string]string{"type": "OS.eplf=unknown"}
+	for _, fact := range strings.Split(line[1:tab], ",") {
+		switch {
+		case fact == "/":
+			facts["type"] = "dir"
+		case fact == "r" && facts["type"] != "dir":
+			facts["type"] = "file"
+		case strings.HasPrefix(fact, "s"):
+			facts["size"] = fact[1:]
+		case strings.HasPrefix(fact, "m"):
+			seconds, err := strconv.ParseInt(fact[1:], 10, 64)
+			if err == nil {
+				facts["modify"] = time.Unix(seconds, 0).UTC().Format("20060102150405")
+			}
+		case strings.HasPrefix(fact, "i"):
+			facts["unique"] = fact[1:]
+		case strings.HasPrefix(fact, "up"):
+			facts["unix.mode"] = fact[2:]
+		}
+	}
+	return NewEntry(line[tab+1:], facts), true
+}
+
+// vmsListingParser parses listings of OpenVMS servers, e.g.
+//
+//	NOTES.TXT;1         12/15      16-OCT-2026 10:30:00  [GROUP,OWNER]  (RWED,RWED,RE,)
+//
+// The version number is removed from the name and directories lose their .DIR
+// extension. The size is given in blocks of 512 bytes. Entries with names too
+// long for their column are wrapped onto two lines by some servers, these are
+// skipped.
+type vmsListingParser struct{}
+
+func (vmsListingParser) Name() string { return "vms" }
+
+func (vmsListingParser) MatchesSystem(system string) bool {
+	return containsSystem(system, "VMS")
+}
+
+func (vmsListingParser) ParseLine(line string) (*Entry, bool) {
+	fields := fieldsWithOffsets(line)
+	if len(fields) < 4 {
+		return nil, false
+	}
+	semicolon := strings.LastIndexByte(fields[0].text, ';')
+	if semicolon == -1 {
+		return nil, false
+	}
+	if _, err := strconv.Atoi(fields[0].text[semicolon+1:]); err != nil {
+		return nil, false
+	}
+	name := fields[0].text[:semicolon]
+	blocks, err := strconv.ParseInt(strings.SplitN(fields[1].text, "/", 2)[0], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	modTime, ok := parseVMSTime(fields[2].text, fields[3].text)
+	if !ok {
+		return nil, false
+	}
+	facts := map[string]string{
+		"type":   "file",
+		"size":   strconv.FormatInt(blocks*512, 10),
+		"modify": modTime.Format("20060102150405"),
+	}
+	if strings.HasSuffix(strings.ToUpper(name), ".DIR") {
+		facts["type"] = "dir"
+		name = name[:len(name)-len(".DIR")]
+	}
+	if len(fields) > 4 && strings.HasPrefix(fields[4].text, "[") {
+		owner := strings.Trim(fields[4].text, "[]")
+		if comma := strings.IndexByte(owner, ','); comma != -1 {
+			facts["unix.group"] = owner[:comma]
+			owner = owner[comma+1:]
+		}
+		facts["unix.owner"] = owner
+	}
+	return NewEntry(name, facts), true
+}
+
+// parseVMSTime parses a date like "16-OCT-2026" and a time like "10:30:00",
+// "10:30" or "10:30:00.25".
+func parseVMSTime(date, clock string) (time.Time, bool) {
+	if dot := strings.IndexByte(clock, '.'); dot != -1 {
+		clock = clock[:dot]
+	}
+	if strings.Count(clock, ":") == 1 {
+		clock += ":00"
+	}
+	t, err := time.Parse("2-Jan-2006 15:04:05", date+" "+clock)
+	return t, err == nil
+}
+
+// as400ListingParser parses listings of IBM i (AS/400) servers, e.g.
+//
+//	QSYS        77824 02/23/00 15:09:55 *DIR       QSYS.LIB/
+//	QPGMR                               *MEM       QGPL.LIB/QCLSRC.FILE/X.MBR
+//
+// Names that end in a slash are directories. The object type, e.g. *FILE, is
+// available as the fact "os.as400.type".
+type as400ListingParser struct{}
+
+func (as400ListingParser) Name() string { return "as400" }
+
+func (as400ListingParser) MatchesSystem(system string) bool {
+	return containsSystem(system, "OS/400", "OS400")
+}
+
+func (as400ListingParser) ParseLine(line string) (*Entry, bool) {
+	fields := fieldsWithOffsets(line)
+	facts := make(map[string]string)
+	var nameField field
+	switch {
+	case len(fields) >= 6 && strings.HasPrefix(fields[4].text, "*"):
+		if _, err := strconv.ParseUint(fields[1].text, 10, 64); err != nil {
+			return nil, false
+		}
+		modTime, ok := parseAS400Time(fields[2].text, fields[3].text)
+		if !ok {
+			return nil, false
+		}
+		facts["size"] = fields[1].text
+		facts["modify"] = modTime.Format("20060102150405")
+		facts["os.as400.type"] = fields[4].text
+		nameField = fields[5]
+	case len(fields) >= 3 && strings.HasPrefix(fields[1].text, "*"):
+		facts["os.as400.type"] = fields[1].text
+		nameField = fields[2]
+	default:
+		return nil, false
+	}
+	facts["unix.owner"] = fields[0].text
+	name := line[nameField.offset:]
+	facts["type"] = "file"
+	if strings.HasSuffix(name, "/") {
+		facts["type"] = "dir"
+		name = strings.TrimSuffix(name, "/")
+	}
+	return NewEntry(path.Base(name), facts), true
+}
+
+// parseAS400Time parses a date as mm/dd/yy or dd.mm.yy, with two or four digit
+// years, and a time like "15:09:55".
+func parseAS400Time(date, clock string) (time.Time, bool) {
+	layout := "01/02/"
+	if strings.Contains(date, ".") {
+		layout = "02.01."
+	}
+	if len(date) == len(layout)+2 {
+		layout += "06"
+	} else {
+		layout += "2006"
+	}
+	t, err := time.Parse(layout+" 15:04:05", date+" "+clock)
+	return t, err == nil
+}
+
+// netwareListingParser parses listings of Novell Netware servers, e.g.
+//
+//	d [RWCEAFMS] owner                512 Jan 18 11:16 dir name
+//	- [R----F--] owner               1234 Jan 18  2019 file.txt
+type netwareListingParser struct{}
+
+func (netwareListingParser) Name() string { return "netware" }
+
+func (netwareListingParser) MatchesSystem(system string) bool {
+	return containsSystem(system, "NETWARE")
+}
+
+func (netwareListingParser) ParseLine(line string) (*Entry, bool) {
+	fields := fieldsWithOffsets(line)
+	if len(fields) < 8 ||
+		!strings.HasPrefix(fields[1].text, "[") || !strings.HasSuffix(fields[1].text, "]") {
+		return nil, false
+	}
+	facts := map[string]string{"unix.owner": fields[2].text, "size": fields[3].text}
+	switch fields[0].text {
+	case "d":
+		facts["type"] = "dir"
+	case "-":
+		facts["type"] = "file"
+	default:
+		return nil, false
+	}
+	if _, err := strconv.ParseUint(fields[3].text, 10, 64); err != nil {
+		return nil, false
+	}
+	modTime, ok := parseUnixDate(fields[4].text, fields[5].text, fields[6].text, time.Now())
+	if !ok {
+		return nil, false
+	}
+	facts["modify"] = modTime.Format("20060102150405")
+	return NewEntry(line[fields[7].offset:], facts), true
+}
+
+// zosListingParser parses data set listings of z/OS (MVS) servers, e.g.
+//
+//	Volume Unit    Referred Ext Used Recfm Lrecl BlkSz Dsorg Dsname
+//	WORK01 3390   2026/10/16  1   15  FB      80  3120  PS  USER.DATA
+//	WORK02 3390   2026/10/15  2   45  FB      80 27920  PO  USER.SOURCE
+//	Migrated                                                USER.OLD
+//
+// Partitioned data sets (PO) are directories of members, all others are files.
+// The listing only has the date of the last access, not of the last
+// modification, so ModTime is not set. The data set organization and volume
+// are available as the facts "os.zos.dsorg" and "os.zos.volume".
+type zosListingParser struct{}
+
+func (zosListingParser) Name() string { return "zos" }
+
+func (zosListingParser) MatchesSystem(system string) bool {
+	return containsSystem(system, "MVS", "Z/OS")
+}
+
+func (zosListingParser) ParseLine(line string) (*Entry, bool) {
+	fields := fieldsWithOffsets(line)
+	if len(fields) == 2 && fields[0].text == "Migrated" {
+		return NewEntry(fields[1].text, map[string]string{"type": "file"}), true
+	}
+	if len(fields) != 10 {
+		return nil, false
+	}
+	if _, err := time.Parse("2006/01/02", fields[2].text); err != nil {
+		return nil, false
+	}
+	dsorg := fields[8].text
+	facts := map[string]string{
+		"type":          "file",
+		"os.zos.dsorg":  dsorg,
+		"os.zos.volume": fields[0].text,
+	}
+	if dsorg == "PO" || dsorg == "PO-E" {
+		facts["type"] = "dir"
+	}
+	return NewEntry(fields[9].text, facts), true
+}
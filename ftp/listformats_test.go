@@ -0,0 +1,109 @@
+package ftp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEPLFLines(t *testing.T) {
+	p := eplfListingParser{}
+	e, ok := p.ParseLine("+i8388621.48594,m825718503,r,s280,up644,\tdjb.html")
+	if !ok {
+		t.Fatal("expected EPLF file")
+	}
+	checkEntryKind(t, e, "djb.html", EntryFile)
+	checkModTime(t, e, time.Unix(825718503, 0))
+	if e.Size() != 280 || e.UniqueID() != "8388621.48594" {
+		t.Errorf("unexpected size %d or unique ID %q", e.Size(), e.UniqueID())
+	}
+	if mode, ok := e.UnixMode(); !ok || mode != 0644 {
+		t.Errorf("expected mode 0644 but got %v", mode)
+	}
+	e, ok = p.ParseLine("+i8388621.50690,m824255907,/,\t514")
+	if !ok {
+		t.Fatal("expected EPLF directory")
+	}
+	checkEntryKind(t, e, "514", EntryDir)
+}
+
+func TestVMSLines(t *testing.T) {
+	p := vmsListingParser{}
+	e, ok := p.ParseLine("NOTES.TXT;12        12/15      16-OCT-2026 10:30:00  [STAFF,BOB]  (RWED,RWED,RE,)")
+	if !ok {
+		t.Fatal("expected VMS file")
+	}
+	checkEntryKind(t, e, "NOTES.TXT", EntryFile)
+	checkModTime(t, e, time.Date(2026, 10, 16, 10, 30, 0, 0, time.UTC))
+	if e.Size() != 12*512 || e.Owner() != "BOB" || e.Group() != "STAFF" {
+		t.Errorf("unexpected size %d, owner %q or group %q", e.Size(), e.Owner(), e.Group())
+	}
+	e, ok = p.ParseLine("ARCHIVE.DIR;1  1  2-JAN-2020 08:05")
+	if !ok {
+		t.Fatal("expected VMS directory")
+	}
+	checkEntryKind(t, e, "ARCHIVE", EntryDir)
+	if _, ok := p.ParseLine("Directory DISK$USER:[BOB]"); ok {
+		t.Error("expected header to be skipped")
+	}
+}
+
+func TestAS400Lines(t *testing.T) {
+	p := as400ListingParser{}
+	e, ok := p.ParseLine("QSYS        77824 02/23/00 15:09:55 *DIR       QSYS.LIB/")
+	if !ok {
+		t.Fatal("expected AS/400 directory")
+	}
+	checkEntryKind(t, e, "QSYS.LIB", EntryDir)
+	checkModTime(t, e, time.Date(2000, 2, 23, 15, 9, 55, 0, time.UTC))
+	if e.Size() != 77824 || e.Owner() != "QSYS" {
+		t.Errorf("unexpected size %d or owner %q", e.Size(), e.Owner())
+	}
+	e, ok = p.ParseLine("QPGMR                               *MEM       QGPL.LIB/QCLSRC.FILE/X.MBR")
+	if !ok {
+		t.Fatal("expected AS/400 member")
+	}
+	checkEntryKind(t, e, "X.MBR", EntryFile)
+	if typ, _ := e.Fact("os.as400.type"); typ != "*MEM" {
+		t.Errorf("expected type *MEM but got %q", typ)
+	}
+}
+
+func TestNetwareLines(t *testing.T) {
+	p := netwareListingParser{}
+	e, ok := p.ParseLine("- [R----F--] bob               1234 Jan 18  2019 my file.txt")
+	if !ok {
+		t.Fatal("expected Netware file")
+	}
+	checkEntryKind(t, e, "my file.txt", EntryFile)
+	checkModTime(t, e, time.Date(2019, 1, 18, 0, 0, 0, 0, time.UTC))
+	if e.Size() != 1234 || e.Owner() != "bob" {
+		t.Errorf("unexpected size %d or owner %q", e.Size(), e.Owner())
+	}
+	e, ok = p.ParseLine("d [RWCEAFMS] bob                512 Jan 18  2019 docs")
+	if !ok {
+		t.Fatal("expected Netware directory")
+	}
+	checkEntryKind(t, e, "docs", EntryDir)
+}
+
+func TestZOSLines(t *testing.T) {
+	p := zosListingParser{}
+	if _, ok := p.ParseLine("Volume Unit    Referred Ext Used Recfm Lrecl BlkSz Dsorg Dsname"); ok {
+		t.Error("expected header to be skipped")
+	}
+	e, ok := p.ParseLine("WORK01 3390   2026/10/16  1   15  FB      80  3120  PS  USER.DATA")
+	if !ok {
+		t.Fatal("expected sequential data set")
+	}
+	checkEntryKind(t, e, "USER.DATA", EntryFile)
+	e, ok = p.ParseLine("WORK02 3390   2026/10/15  2   45  FB      80 27920  PO  USER.SOURCE")
+	if !ok {
+		t.Fatal("expected partitioned data set")
+	}
+	checkEntryKind(t, e, "USER.SOURCE", EntryDir)
+	e, ok = p.ParseLine("Migrated                                                USER.OLD")
+	if !ok {
+		t.Fatal("expected migrated data set")
+	}
+	checkEntryKind(t, e, "USER.OLD", EntryFile)
+}
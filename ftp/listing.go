@@ -0,0 +1,143 @@
+package ftp
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// ListingParser parses the lines of LIST output in one server specific format,
+// see ParseListing and RegisterListingParser.
+type ListingParser interface {
+	// Name identifies the format, e.g. "unix" or "dos".
+	Name() string
+	// MatchesSystem reports whether servers that describe their system like
+	// this in their reply to SYST are likely to use this format, see
+	// Connection.System. Parsers that match are tried first.
+	MatchesSystem(system string) bool
+	// ParseLine parses a single line of a listing. It returns false if the
+	// line is not in this format or does not describe an entry, e.g. a header
+	// or a summary line.
+	ParseLine(line string) (entry *Entry, ok bool)
+}
+
+var (
+	listingParsersMu sync.RWMutex
+	listingParsers   []ListingParser
+)
+
+// builtinListingParsers are tried after all registered parsers, in this order.
+var builtinListingParsers = []ListingParser{
+	unixListingParser{},
+	dosListingParser{},
+	eplfListingParser{},
+	vmsListingParser{},
+	as400ListingParser{},
+	netwareListingParser{},
+	zosListingParser{},
+}
+
+// RegisterListingParser makes ParseListing, and thus ListEntriesIn, try the
+// given parser before the built-in ones. Use this to support the format of
+// your own servers. Parsers that were registered later are tried first.
+func RegisterListingParser(parser ListingParser) {
+	listingParsersMu.Lock()
+	defer listingParsersMu.Unlock()
+	listingParsers = append([]ListingParser{parser}, listingParsers...)
+}
+
+// listingParsersFor returns all parsers, the ones that match the system first.
+func listingParsersFor(system string) []ListingParser {
+	listingParsersMu.RLock()
+	all := append(append([]ListingParser{}, listingParsers...), builtinListingParsers...)
+	listingParsersMu.RUnlock()
+	var matching, others []ListingParser
+	for _, p := range all {
+		if p.MatchesSystem(system) {
+			matching = append(matching, p)
+		} else {
+			others = append(others, p)
+		}
+	}
+	return append(matching, others...)
+}
+
+// ParseListing parses the output of LIST. The system is the server's reply to
+// SYST, see Connection.System, it may be empty. The format is detected by
+// trying all parsers, registered ones first, on each line until one of them
+// parses it. This parser is used for the rest of the listing, lines that it
+// cannot parse are skipped.
+// The built-in formats are the Unix "ls -l" format (see ParseUnixListing),
+// MS-DOS/IIS (see ParseDOSListing), EPLF, VMS, AS/400, Netware and z/OS data
+// sets.
+func ParseListing(listing, system string) []*Entry {
+	parsers := listingParsersFor(system)
+	var entries []*Entry
+	for _, line := range listingLines(listing) {
+		for _, p := range parsers {
+			if e, ok := p.ParseLine(line); ok {
+				if e.raw == "" {
+					e.raw = line
+				}
+				entries = append(entries, e)
+				parsers = []ListingParser{p}
+				break
+			}
+		}
+	}
+	return entries
+}
+
+// parseListingWith parses all lines of a listing with the given function,
+// skipping lines that it cannot parse.
+func parseListingWith(listing string, parseLine func(string) (*Entry, bool)) []*Entry {
+	var entries []*Entry
+	for _, line := range listingLines(listing) {
+		if e, ok := parseLine(line); ok {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func listingLines(listing string) []string {
+	lines := strings.Split(listing, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+	return lines
+}
+
+func containsSystem(system string, names ...string) bool {
+	system = strings.ToUpper(system)
+	for _, name := range names {
+		if strings.Contains(system, name) {
+			return true
+		}
+	}
+	return false
+}
+
+type unixListingParser struct{}
+
+func (unixListingParser) Name() string { return "unix" }
+
+func (unixListingParser) MatchesSystem(system string) bool {
+	return containsSystem(system, "UNIX", "LINUX")
+}
+
+func (unixListingParser) ParseLine(line string) (*Entry, bool) {
+	return parseUnixLine(line, time.Now())
+}
+
+type dosListingParser struct{}
+
+func (dosListingParser) Name() string { return "dos" }
+
+func (dosListingParser) MatchesSystem(system string) bool {
+	return containsSystem(system, "WINDOWS", "MS-DOS")
+}
+
+func (dosListingParser) ParseLine(line string) (*Entry, bool) {
+	return parseDOSLine(line)
+}
@@ -0,0 +1,61 @@
+package ftp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListingFormatIsDetectedFromFirstParsedLine(t *testing.T) {
+	entries := ParseListing("total 8\r\n"+
+		"-rw-r--r-- 1 bob staff 12 Jan  1  2019 a.txt\r\n"+
+		"01-02-2020  14:05  12 looks like dos.txt\r\n"+
+		"drwxr-xr-x 2 bob staff 4096 Jan  1  2019 dir\r\n", "")
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries but got %d", len(entries))
+	}
+	checkEntryKind(t, entries[0], "a.txt", EntryFile)
+	checkEntryKind(t, entries[1], "dir", EntryDir)
+	if entries[0].Raw() != "-rw-r--r-- 1 bob staff 12 Jan  1  2019 a.txt" {
+		t.Errorf("unexpected raw line %q", entries[0].Raw())
+	}
+}
+
+func TestParsersMatchingTheSystemAreTriedFirst(t *testing.T) {
+	parsers := listingParsersFor("Windows_NT")
+	if parsers[0].Name() != "dos" {
+		t.Errorf("expected dos parser first but got %s", parsers[0].Name())
+	}
+	parsers = listingParsersFor("UNIX Type: L8")
+	if parsers[0].Name() != "unix" {
+		t.Errorf("expected unix parser first but got %s", parsers[0].Name())
+	}
+}
+
+func TestRegisteredParsersAreTriedBeforeBuiltIns(t *testing.T) {
+	defer func(old []ListingParser) { listingParsers = old }(listingParsers)
+	RegisterListingParser(prefixParser{})
+
+	entries := ParseListing("custom:my file\r\n-rw-r--r-- 1 bob staff 12 Jan  1  2019 a.txt\r\n", "")
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry but got %d", len(entries))
+	}
+	checkEntryKind(t, entries[0], "my file", EntryFile)
+	if entries[0].Raw() != "custom:my file" {
+		t.Errorf("unexpected raw line %q", entries[0].Raw())
+	}
+}
+
+// test helpers
+
+type prefixParser struct{}
+
+func (prefixParser) Name() string { return "prefix" }
+
+func (prefixParser) MatchesSystem(string) bool { return false }
+
+func (prefixParser) ParseLine(line string) (*Entry, bool) {
+	if !strings.HasPrefix(line, "custom:") {
+		return nil, false
+	}
+	return NewEntry(strings.TrimPrefix(line, "custom:"), map[string]string{"type": "file"}), true
+}
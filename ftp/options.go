@@ -0,0 +1,147 @@
+package ftp
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// Options sets options for the given command as described in RFC 2389, e.g.
+// Options("UTF8", "ON"). The connection records the options that change how
+// it talks to the server, see EnableUTF8 and SelectMLSTFacts.
+// The FTP command this sends is OPTS.
+func (c *Connection) Options(command, options string) error {
+	_, err := c.options(command, options)
+	return err
+}
+
+func (c *Connection) options(command, options string) ([]byte, error) {
+	args := []string{"OPTS", command}
+	if options != "" {
+		args = append(args, options)
+	}
+	resp, code, err := c.sendAndReceive(args...)
+	if err != nil {
+		return nil, err
+	}
+	if code != CodeCommandOK && code != CodeCommandSuperfluous {
+		return nil, errorMessage("OPTS", resp)
+	}
+	switch strings.ToUpper(command) {
+	case "UTF8":
+		c.utf8 = strings.EqualFold(options, "ON")
+	case "MLST":
+		c.selectMLSTFacts(mlstFactsOfOptsResponse(resp, options))
+	}
+	return resp, nil
+}
+
+// EnableUTF8 tells the server to use UTF-8 for path names (RFC 2640). This is
+// needed for servers that use a legacy character set by default.
+// Without it, names that are not valid UTF-8 are assumed to be ISO-8859-1
+// (Latin-1) and converted to UTF-8. Once the connection has received such a
+// name, paths are converted back to ISO-8859-1 when they are sent.
+// The FTP command this sends is OPTS UTF8 ON.
+func (c *Connection) EnableUTF8() error {
+	return c.Options("UTF8", "ON")
+}
+
+// SelectMLSTFacts tells the server which facts to list for MLST and MLSD, e.g.
+// "type", "size", "modify" and "perm". It returns the facts that the server
+// actually selected, which may be fewer if it does not support all of them.
+// The FTP command this sends is OPTS MLST.
+func (c *Connection) SelectMLSTFacts(facts ...string) ([]string, error) {
+	for _, fact := range facts {
+		if fact == "" || strings.ContainsAny(fact, "; \r\n") {
+			return nil, errors.New("ftp: invalid MLST fact name: " + fact)
+		}
+	}
+	options := ""
+	if len(facts) > 0 {
+		options = strings.Join(facts, ";") + ";"
+	}
+	resp, err := c.options("MLST", options)
+	if err != nil {
+		return nil, err
+	}
+	return mlstFactsOfOptsResponse(resp, options), nil
+}
+
+// mlstFactsOfOptsResponse returns the facts that the server confirms in its
+// reply to OPTS MLST, e.g. "200 MLST OPTS type;size;". If the reply does not
+// list them, the requested facts are assumed.
+func mlstFactsOfOptsResponse(resp []byte, requested string) []string {
+	lines := replyLines(resp)
+	facts := requested
+	if len(lines) > 0 {
+		fields := strings.Fields(lines[len(lines)-1])
+		if len(fields) >= 2 && strings.EqualFold(fields[0], "MLST") &&
+			strings.EqualFold(fields[1], "OPTS") {
+			facts = strings.Join(fields[2:], "")
+		}
+	}
+	var list []string
+	for _, fact := range strings.Split(facts, ";") {
+		if fact != "" {
+			list = append(list, strings.ToLower(fact))
+		}
+	}
+	return list
+}
+
+// selectMLSTFacts updates the cached features so that
+// Features.EnabledMLSTFacts reflects the selected facts.
+func (c *Connection) selectMLSTFacts(facts []string) {
+	if c.featureSet == nil || !c.featureSet.Has("MLST") {
+		return
+	}
+	params := ""
+	for _, fact := range c.featureSet.MLSTFacts() {
+		params += fact
+		if containsFold(facts, fact) {
+			params += "*"
+		}
+		params += ";"
+	}
+	c.featureSet.params["MLST"] = params
+}
+
+// decodeName converts a name that the server sent to UTF-8. Names that are not
+// valid UTF-8 are assumed to be ISO-8859-1 unless UTF-8 was negotiated.
+func (c *Connection) decodeName(name string) string {
+	if c.utf8 || utf8.ValidString(name) {
+		return name
+	}
+	c.latin1 = true
+	return decodeLatin1(name)
+}
+
+// encodeName converts a name to the character set of the server, see
+// EnableUTF8.
+func (c *Connection) encodeName(name string) string {
+	if c.utf8 || !c.latin1 {
+		return name
+	}
+	return encodeLatin1(name)
+}
+
+func decodeLatin1(s string) string {
+	runes := make([]rune, len(s))
+	for i := 0; i < len(s); i++ {
+		runes[i] = rune(s[i])
+	}
+	return string(runes)
+}
+
+// encodeLatin1 converts s to ISO-8859-1. If s contains characters that do not
+// exist in ISO-8859-1, it is returned unchanged.
+func encodeLatin1(s string) string {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xFF {
+			return s
+		}
+		b = append(b, byte(r))
+	}
+	return string(b)
+}
@@ -0,0 +1,105 @@
+package ftp
+
+import (
+	"bufio"
+	"testing"
+)
+
+func TestMLSTFactsAreTakenFromOptsResponse(t *testing.T) {
+	checkStrings(t,
+		mlstFactsOfOptsResponse([]byte("200 MLST OPTS Type;Size;\r\n"), "type;size;perm;"),
+		"type", "size")
+	checkStrings(t,
+		mlstFactsOfOptsResponse([]byte("200 OK\r\n"), "type;size;"),
+		"type", "size")
+	checkStrings(t,
+		mlstFactsOfOptsResponse([]byte("200 MLST OPTS\r\n"), "type;"))
+}
+
+func TestInvalidUTF8NamesAreDecodedAsLatin1(t *testing.T) {
+	c := &Connection{session: &session{}}
+	if name := c.decodeName("Gr\xfc\xdfe.txt"); name != "Grüße.txt" {
+		t.Errorf("expected Latin-1 decoding but got %q", name)
+	}
+	if name := c.encodeName("Grüße.txt"); name != "Gr\xfc\xdfe.txt" {
+		t.Errorf("expected Latin-1 encoding but got %q", name)
+	}
+	if name := c.encodeName("日本.txt"); name != "日本.txt" {
+		t.Errorf("expected names outside Latin-1 to stay UTF-8 but got %q", name)
+	}
+}
+
+func TestNamesAreNotConvertedWithoutLatin1(t *testing.T) {
+	c := &Connection{session: &session{}}
+	if name := c.decodeName("Grüße.txt"); name != "Grüße.txt" {
+		t.Errorf("expected valid UTF-8 to stay unchanged but got %q", name)
+	}
+	if name := c.encodeName("Grüße.txt"); name != "Grüße.txt" {
+		t.Errorf("expected UTF-8 encoding but got %q", name)
+	}
+}
+
+func TestUTF8OptionIsRecorded(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	go func() {
+		line, _ := bufio.NewReader(server).ReadString('\n')
+		if line != "OPTS UTF8 ON\r\n" {
+			server.Write([]byte("500 unexpected " + line))
+		} else {
+			server.Write([]byte("200 UTF8 enabled\r\n"))
+		}
+	}()
+
+	if err := c.EnableUTF8(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.utf8 {
+		t.Error("expected UTF-8 to be recorded")
+	}
+	if name := c.decodeName("Gr\xfc\xdfe.txt"); name != "Gr\xfc\xdfe.txt" {
+		t.Errorf("expected no Latin-1 decoding with UTF-8 but got %q", name)
+	}
+}
+
+func TestReinitializeResetsNameEncoding(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	go func() {
+		bufio.NewReader(server).ReadString('\n')
+		server.Write([]byte("220 ready\r\n"))
+	}()
+	c.utf8 = true
+	c.latin1 = true
+
+	if err := c.Reinitialize(); err != nil {
+		t.Fatal(err)
+	}
+	if c.utf8 || c.latin1 {
+		t.Error("expected name encoding to be reset")
+	}
+}
+
+func TestOnlyPathsAreConvertedToLatin1(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	commands := serveScript(c, server, map[string]string{
+		"USER": "331 password please",
+		"PASS": "230 logged in",
+		"SITE": "200 ok",
+		"DELE": "250 deleted",
+	}, nil)
+	c.latin1 = true
+
+	if err := c.Login("jürgen", "pässword"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Site("ECHO Grüße"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete("Grüße.txt"); err != nil {
+		t.Fatal(err)
+	}
+	checkStrings(t, commandsOf(commands),
+		"USER jürgen", "PASS pässword", "SITE ECHO Grüße", "DELE Gr\xfc\xdfe.txt")
+}
@@ -0,0 +1,127 @@
+package ftp
+
+import (
+	"errors"
+	"strings"
+)
+
+// Reply is a complete, possibly multi-line, reply of the FTP server on the
+// control connection.
+type Reply struct {
+	// Code is the three digit reply code, e.g. 200. It is 0 if the reply did
+	// not start with a valid code.
+	Code ReplyCode
+	// Lines are the lines of the reply without reply codes and line breaks.
+	Lines []string
+	// Raw is the reply as it was received, including reply codes and line
+	// breaks.
+	Raw []byte
+}
+
+func newReply(response []byte) *Reply {
+	return &Reply{
+		Code:  extractCode(response),
+		Lines: replyLines(response),
+		Raw:   response,
+	}
+}
+
+// Message returns the lines of the reply separated by line feeds (\n).
+func (r *Reply) Message() string {
+	return strings.Join(r.Lines, "\n")
+}
+
+// Quote sends the given command line to the FTP server as is and returns the
+// server's reply. Use this for commands that this package does not implement,
+// e.g. "SITE CHMOD 644 file.txt" or "ALLO 1024".
+// If the server replies with an error code (4xx or 5xx), the reply is returned
+// together with an *Error.
+// Do not use Quote for commands that transfer data, like RETR or LIST, the
+// server would wait for a data connection.
+func (c *Connection) Quote(line string) (*Reply, error) {
+	err := checkQuotedLine(line)
+	if err != nil {
+		return nil, err
+	}
+	resp, code, err := c.sendAndReceive(line)
+	if err != nil {
+		return nil, err
+	}
+	reply := newReply(resp)
+	if code == 0 || code.Transient() || code.Permanent() {
+		return reply, errorMessage(commandOf(line), resp)
+	}
+	return reply, nil
+}
+
+// Site sends a site specific command, i.e. a command that is not part of the
+// FTP standard but implemented by the server, like "CHMOD 644 file.txt" or
+// "UMASK 022". Use HelpAbout("SITE") to get a list of supported commands. Like
+// Quote, it returns the server's reply and an *Error for error codes.
+// The FTP command this sends is SITE.
+func (c *Connection) Site(command string) (*Reply, error) {
+	return c.Quote("SITE " + command)
+}
+
+// QuoteData sends the given command line to the FTP server as is, like Quote,
+// but for commands that transfer data from the server over a data connection,
+// e.g. vendor specific listing commands. It returns a stream of the data as it
+// is received. The data is transferred in binary mode, i.e. it is returned
+// exactly as the server sent it.
+// You have to Close the returned stream when you are done. Close returns an
+// error if the server reports that the transfer failed, afterwards Reply
+// returns the server's final reply.
+// No other commands can be sent on the connection until the stream is closed,
+// they fail with ErrTransferInProgress.
+func (c *Connection) QuoteData(line string) (*DataStream, error) {
+	err := checkQuotedLine(line)
+	if err != nil {
+		return nil, err
+	}
+	err = c.setBinaryTransfer()
+	if err != nil {
+		return nil, err
+	}
+	stream, err := c.openStream(line, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	return &DataStream{stream: stream}, nil
+}
+
+// DataStream is the data sent by the server for a command started with
+// QuoteData.
+type DataStream struct {
+	stream *transferStream
+}
+
+// Read reads the data sent by the server.
+func (s *DataStream) Read(p []byte) (int, error) {
+	return s.stream.Read(p)
+}
+
+// Close finishes the transfer and reads the server's final reply.
+func (s *DataStream) Close() error {
+	return s.stream.Close()
+}
+
+// Reply returns the server's final reply after the stream was closed
+// successfully. It returns nil before that.
+func (s *DataStream) Reply() *Reply {
+	return s.stream.reply
+}
+
+func checkQuotedLine(line string) error {
+	if strings.ContainsAny(line, "\r\n") {
+		return errors.New("ftp: quoted command must not contain line breaks")
+	}
+	return nil
+}
+
+// commandOf returns the command name, i.e. the first word, of a command line.
+func commandOf(line string) string {
+	if i := strings.IndexByte(line, ' '); i != -1 {
+		return line[:i]
+	}
+	return line
+}
@@ -0,0 +1,210 @@
+package ftp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestReplyHasCodeAndLines(t *testing.T) {
+	reply := newReply([]byte("211-Features:\r\n MDTM\r\n SIZE\r\n211 End\r\n"))
+	if reply.Code != CodeSystemStatus {
+		t.Errorf("expected code 211 but was %v", reply.Code)
+	}
+	checkLines(t, reply.Lines, "Features:", " MDTM", " SIZE", "End")
+	if reply.Message() != "Features:\n MDTM\n SIZE\nEnd" {
+		t.Errorf("unexpected message %q", reply.Message())
+	}
+}
+
+func TestReplyCodeClasses(t *testing.T) {
+	checkClass(t, CodeFileStatusOK, ReplyCode.Preliminary)
+	checkClass(t, CodeCommandOK, ReplyCode.Completion)
+	checkClass(t, CodeUserNameOK, ReplyCode.Intermediate)
+	checkClass(t, CodeServiceNotAvailable, ReplyCode.Transient)
+	checkClass(t, CodeFileUnavailable, ReplyCode.Permanent)
+	if ReplyCode(0).Completion() || ReplyCode(600).Permanent() {
+		t.Error("invalid codes should not be in any class")
+	}
+}
+
+func TestQuoteSendsLineAsIs(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	go func() {
+		line, _ := bufio.NewReader(server).ReadString('\n')
+		if line != "SITE CHMOD 644 file.txt\r\n" {
+			server.Write([]byte("500 unexpected " + line))
+		} else {
+			server.Write([]byte("200 CHMOD done\r\n"))
+		}
+	}()
+
+	reply, err := c.Quote("SITE CHMOD 644 file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Code != CodeCommandOK || reply.Message() != "CHMOD done" {
+		t.Errorf("unexpected reply %q", reply.Raw)
+	}
+}
+
+func TestQuoteReturnsErrorAndReplyForErrorCodes(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	go func() {
+		bufio.NewReader(server).ReadString('\n')
+		server.Write([]byte("550 No such file\r\n"))
+	}()
+
+	reply, err := c.Quote("SITE CHMOD 644 missing.txt")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+	var ftpErr *Error
+	if errors.As(err, &ftpErr) && ftpErr.Command != "SITE" {
+		t.Errorf("expected command SITE but was %v", ftpErr.Command)
+	}
+	if reply == nil || reply.Code != CodeFileUnavailable {
+		t.Errorf("expected reply with code 550 but got %v", reply)
+	}
+}
+
+func TestSiteCommandsArePrefixed(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	go func() {
+		line, _ := bufio.NewReader(server).ReadString('\n')
+		server.Write([]byte("200 " + line))
+	}()
+
+	reply, err := c.Site("UMASK 022")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Message() != "SITE UMASK 022" {
+		t.Errorf("unexpected command %q", reply.Message())
+	}
+}
+
+func TestQuoteRejectsLineBreaks(t *testing.T) {
+	c, _ := pipeConnection()
+	defer c.Close()
+	_, err := c.Quote("NOOP\r\nDELE file.txt")
+	if err == nil {
+		t.Error("expected error for command with line break")
+	}
+	_, err = c.QuoteData("LIST\nDELE file.txt")
+	if err == nil {
+		t.Error("expected error for data command with line break")
+	}
+}
+
+func TestQuoteDataStreamsDataAndReturnsFinalReply(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	commands := serveScript(c, server, map[string]string{
+		"TYPE": "200 binary",
+		"PASV": "227 Entering Passive Mode (127,0,0,1,4,1)",
+		"SITE": "150 sending listing",
+	}, func(dataConn net.Conn) {
+		dataConn.Write([]byte("file.txt\r\n"))
+	})
+
+	data, err := c.QuoteData("SITE LISTING")
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(data).ReadString('\n')
+	if err != nil || line != "file.txt\r\n" {
+		t.Errorf("unexpected data %q, %v", line, err)
+	}
+	if err := c.NoOperation(); err != ErrTransferInProgress {
+		t.Errorf("expected ErrTransferInProgress but got %v", err)
+	}
+	if data.Reply() != nil {
+		t.Error("reply should not be available before Close")
+	}
+	if err := data.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data.Reply() == nil || data.Reply().Code != CodeClosingDataConnection {
+		t.Errorf("expected final reply 226 but got %v", data.Reply())
+	}
+	checkStrings(t, commandsOf(commands), "TYPE I", "PASV", "SITE LISTING")
+}
+
+// test helpers
+
+func checkClass(t *testing.T, code ReplyCode, class func(ReplyCode) bool) {
+	if !class(code) {
+		t.Errorf("%v is not in the expected class", code)
+	}
+}
+
+// pipeConnection returns a Connection on an in-memory pipe and the server's end
+// of that pipe.
+func pipeConnection() (*Connection, net.Conn) {
+	client, server := net.Pipe()
+	return &Connection{session: &session{conn: client}}, server
+}
+
+// serveScript answers the commands sent on the server end of a pipeConnection
+// with the given replies, keyed by command name, and records the command lines
+// in the returned channel. Commands without a reply are answered with 502.
+// After a 150 reply, transferData is called with the server end of the data
+// connection, the data connection is closed and the transfer completes with
+// 226.
+func serveScript(
+	c *Connection,
+	server net.Conn,
+	replies map[string]string,
+	transferData func(dataConn net.Conn),
+) chan string {
+	dataConns := make(chan net.Conn, 1)
+	c.config.Dial = func(context.Context, string, string) (net.Conn, error) {
+		client, server := net.Pipe()
+		dataConns <- server
+		return client, nil
+	}
+	commands := make(chan string, 100)
+	go func() {
+		r := bufio.NewReader(server)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSuffix(line, "\r\n")
+			commands <- line
+			reply, ok := replies[strings.Fields(line)[0]]
+			if !ok {
+				reply = "502 not implemented"
+			}
+			server.Write([]byte(reply + "\r\n"))
+			if strings.HasPrefix(reply, "150") {
+				dataConn := <-dataConns
+				transferData(dataConn)
+				dataConn.Close()
+				server.Write([]byte("226 transfer complete\r\n"))
+			}
+		}
+	}()
+	return commands
+}
+
+// commandsOf returns the commands that were recorded by serveScript so far.
+func commandsOf(commands chan string) []string {
+	var received []string
+	for {
+		select {
+		case cmd := <-commands:
+			received = append(received, cmd)
+		default:
+			return received
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package ftp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+)
+
+// ErrRestartNotSupported is returned when a transfer should start at an offset
+// but the server does not advertise REST STREAM in its reply to FEAT.
+var ErrRestartNotSupported = errors.New("ftp: server does not support REST STREAM")
+
+// ErrResumeNotSupported is returned by ResumeUpload if the server supports
+// neither REST STREAM nor APPE.
+var ErrResumeNotSupported = errors.New("ftp: server supports neither REST STREAM nor APPE")
+
+// DownloadFrom writes the contents of the file at the given path into the
+// given writer, starting at the given byte offset. Use it to continue a
+// download that was interrupted, passing the number of bytes that were already
+// received as the offset.
+// If offset is not 0, the server has to advertise REST STREAM in its features,
+// otherwise ErrRestartNotSupported is returned.
+// It reads the file as binary data from the FTP server.
+// The FTP commands this sends are FEAT, REST and RETR.
+func (c *Connection) DownloadFrom(path string, offset int64, dest io.Writer) error {
+	err := c.prepareRestart(offset)
+	if err != nil {
+		return err
+	}
+	_, err = c.transferGetReply("RETR", path, offset, func(dataConn net.Conn) error {
+		_, err := io.Copy(dest, dataConn)
+		return err
+	})
+	return err
+}
+
+// RetrieveFrom works like Retrieve but starts reading the file at the given
+// byte offset, see DownloadFrom.
+// The FTP commands this sends are FEAT, REST and RETR.
+func (c *Connection) RetrieveFrom(path string, offset int64) (io.ReadCloser, error) {
+	err := c.prepareRestart(offset)
+	if err != nil {
+		return nil, err
+	}
+	return c.openStream("RETR", path, offset)
+}
+
+// ResumeDownload downloads the file at the given path on the server into the
+// local file at localPath. If the local file exists, only the part of the
+// remote file that comes after the local file's size is downloaded and
+// appended to it. Otherwise the local file is created and the whole file is
+// downloaded. If the local file is already complete, nothing is downloaded. If
+// it is larger than the remote file, an error is returned.
+// The FTP commands this sends are SIZE, FEAT, REST and RETR.
+func (c *Connection) ResumeDownload(path, localPath string) error {
+	file, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	err = c.resumeDownload(path, file)
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func (c *Connection) resumeDownload(path string, file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	offset := info.Size()
+	if offset == 0 {
+		return c.DownloadFrom(path, 0, file)
+	}
+	size, err := c.Size(path)
+	if err != nil {
+		return err
+	}
+	if offset > size {
+		return errors.New("ftp: local file is larger than the remote file")
+	}
+	if offset == size {
+		return nil
+	}
+	return c.DownloadFrom(path, offset, file)
+}
+
+// ResumeUpload writes the contents of the given source to a file at the given
+// path on the server, continuing an upload that was interrupted. It asks the
+// server for the size of the file and only sends the part of the source that
+// comes after it. If the file does not exist yet, the whole source is
+// uploaded.
+// If the server advertises REST STREAM, the upload is restarted at the remote
+// file's size. Otherwise the rest is appended to the file using APPE. If the
+// server does not implement APPE either, ErrResumeNotSupported is returned.
+// The file is written as binary data.
+// The FTP commands this sends are SIZE, FEAT, REST and STOR or APPE.
+func (c *Connection) ResumeUpload(source io.ReadSeeker, path string) error {
+	offset, err := c.Size(path)
+	if errors.Is(err, ErrNotFound) {
+		offset = 0
+	} else if err != nil {
+		return err
+	}
+	end, err := source.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if offset > end {
+		return errors.New("ftp: remote file is larger than the upload source")
+	}
+	_, err = source.Seek(offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	if offset == 0 {
+		return c.upload("STOR", path, 0, source)
+	}
+	if offset == end {
+		return nil
+	}
+	restart, err := c.supportsRestartStream()
+	if err != nil {
+		return err
+	}
+	if restart {
+		return c.upload("STOR", path, offset, source)
+	}
+	err = c.upload("APPE", path, 0, source)
+	if isNotImplemented(err) {
+		return ErrResumeNotSupported
+	}
+	return err
+}
+
+// isNotImplemented reports whether err is the server's reply to a command that
+// it does not know.
+func isNotImplemented(err error) bool {
+	var ftpErr *Error
+	return errors.As(err, &ftpErr) &&
+		(ftpErr.Code == CodeSyntaxError || ftpErr.Code == CodeCommandNotImplemented)
+}
+
+// prepareRestart switches to binary mode, which byte offsets refer to, and
+// makes sure that the server supports REST STREAM if offset is not 0.
+func (c *Connection) prepareRestart(offset int64) error {
+	if offset < 0 {
+		return errors.New("ftp: negative restart offset")
+	}
+	if offset > 0 {
+		ok, err := c.supportsRestartStream()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrRestartNotSupported
+		}
+	}
+	return c.setBinaryTransfer()
+}
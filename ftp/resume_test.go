@@ -0,0 +1,216 @@
+package ftp
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadFromOffsetRequiresRestStream(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	go func() {
+		r := bufio.NewReader(server)
+		r.ReadString('\n')
+		server.Write([]byte("211-Features:\r\n SIZE\r\n211 End\r\n"))
+		line, _ := r.ReadString('\n')
+		server.Write([]byte("500 unexpected " + line))
+	}()
+
+	err := c.DownloadFrom("file.txt", 100, &bytes.Buffer{})
+	if err != ErrRestartNotSupported {
+		t.Errorf("expected ErrRestartNotSupported but got %v", err)
+	}
+}
+
+func TestResumeDownloadAppendsRestOfRemoteFile(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	commands := serveScript(c, server, map[string]string{
+		"TYPE": "200 binary",
+		"SIZE": "213 5",
+		"FEAT": "211-Features:\r\n REST STREAM\r\n211 End",
+		"PASV": "227 Entering Passive Mode (127,0,0,1,4,1)",
+		"REST": "350 restarting at 3",
+		"RETR": "150 sending",
+	}, func(dataConn net.Conn) {
+		dataConn.Write([]byte("lo"))
+	})
+	local := localFile(t, "hel")
+
+	err := c.ResumeDownload("file.txt", local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLocalFile(t, local, "hello")
+	checkStrings(t, commandsOf(commands),
+		"TYPE I", "SIZE file.txt", "FEAT", "PASV", "REST 3", "RETR file.txt")
+}
+
+func TestResumeDownloadOfCompleteFileDoesNothing(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	commands := serveScript(c, server, map[string]string{
+		"TYPE": "200 binary",
+		"SIZE": "213 5",
+	}, nil)
+	local := localFile(t, "hello")
+
+	err := c.ResumeDownload("file.txt", local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLocalFile(t, local, "hello")
+	checkStrings(t, commandsOf(commands), "TYPE I", "SIZE file.txt")
+}
+
+func TestResumeDownloadFailsIfLocalFileIsLarger(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	commands := serveScript(c, server, map[string]string{
+		"TYPE": "200 binary",
+		"SIZE": "213 3",
+	}, nil)
+	local := localFile(t, "hello")
+
+	err := c.ResumeDownload("file.txt", local)
+	if err == nil {
+		t.Error("expected error for local file larger than remote file")
+	}
+	checkLocalFile(t, local, "hello")
+	checkStrings(t, commandsOf(commands), "TYPE I", "SIZE file.txt")
+}
+
+func TestResumeUploadRestartsStoreIfServerSupportsRestStream(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	var uploaded []byte
+	commands := serveScript(c, server, map[string]string{
+		"TYPE": "200 binary",
+		"SIZE": "213 3",
+		"FEAT": "211-Features:\r\n REST STREAM\r\n211 End",
+		"PASV": "227 Entering Passive Mode (127,0,0,1,4,1)",
+		"REST": "350 restarting at 3",
+		"STOR": "150 receiving",
+	}, func(dataConn net.Conn) {
+		uploaded, _ = ioutil.ReadAll(dataConn)
+	})
+
+	err := c.ResumeUpload(strings.NewReader("hello"), "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(uploaded) != "lo" {
+		t.Errorf("expected rest of file %q but uploaded %q", "lo", uploaded)
+	}
+	checkStrings(t, commandsOf(commands),
+		"TYPE I", "SIZE file.txt", "FEAT", "PASV", "REST 3", "STOR file.txt")
+}
+
+func TestResumeUploadAppendsIfServerDoesNotSupportRestStream(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	var uploaded []byte
+	commands := serveScript(c, server, map[string]string{
+		"TYPE": "200 binary",
+		"SIZE": "213 3",
+		"FEAT": "211-Features:\r\n SIZE\r\n211 End",
+		"PASV": "227 Entering Passive Mode (127,0,0,1,4,1)",
+		"APPE": "150 receiving",
+	}, func(dataConn net.Conn) {
+		uploaded, _ = ioutil.ReadAll(dataConn)
+	})
+
+	err := c.ResumeUpload(strings.NewReader("hello"), "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(uploaded) != "lo" {
+		t.Errorf("expected rest of file %q but uploaded %q", "lo", uploaded)
+	}
+	checkStrings(t, commandsOf(commands),
+		"TYPE I", "SIZE file.txt", "FEAT", "PASV", "APPE file.txt")
+}
+
+func TestResumeUploadFailsIfServerDoesNotImplementAppend(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	serveScript(c, server, map[string]string{
+		"TYPE": "200 binary",
+		"SIZE": "213 3",
+		"FEAT": "211-Features:\r\n SIZE\r\n211 End",
+		"PASV": "227 Entering Passive Mode (127,0,0,1,4,1)",
+		"APPE": "502 command not implemented",
+	}, nil)
+
+	err := c.ResumeUpload(strings.NewReader("hello"), "file.txt")
+	if err != ErrResumeNotSupported {
+		t.Errorf("expected ErrResumeNotSupported but got %v", err)
+	}
+}
+
+func TestResumeUploadStoresWholeFileIfItIsMissing(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	var uploaded []byte
+	commands := serveScript(c, server, map[string]string{
+		"TYPE": "200 binary",
+		"SIZE": "550 file not found",
+		"PASV": "227 Entering Passive Mode (127,0,0,1,4,1)",
+		"STOR": "150 receiving",
+	}, func(dataConn net.Conn) {
+		uploaded, _ = ioutil.ReadAll(dataConn)
+	})
+
+	err := c.ResumeUpload(strings.NewReader("hello"), "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(uploaded) != "hello" {
+		t.Errorf("expected whole file %q but uploaded %q", "hello", uploaded)
+	}
+	checkStrings(t, commandsOf(commands),
+		"TYPE I", "SIZE file.txt", "PASV", "STOR file.txt")
+}
+
+func TestResumeUploadOfCompleteFileDoesNothing(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	commands := serveScript(c, server, map[string]string{
+		"TYPE": "200 binary",
+		"SIZE": "213 5",
+	}, nil)
+
+	err := c.ResumeUpload(strings.NewReader("hello"), "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStrings(t, commandsOf(commands), "TYPE I", "SIZE file.txt")
+}
+
+// test helpers
+
+// localFile creates a temporary file with the given content and returns its
+// path.
+func localFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "local.txt")
+	err := ioutil.WriteFile(path, []byte(content), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func checkLocalFile(t *testing.T, path, expected string) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != expected {
+		t.Errorf("expected local file %q but was %q", expected, data)
+	}
+}
@@ -0,0 +1,123 @@
+package ftp
+
+import (
+	"errors"
+	"io"
+	"net"
+)
+
+// Retrieve starts downloading the file at the given path and returns a reader
+// for its contents. This is useful if you want to process the data while it
+// is received, e.g. with a parser or archive/zip, instead of using Download.
+// You have to Close the returned reader when you are done. Close returns an
+// error if the server reports that the transfer failed. If you close it before
+// reading all data, the transfer is aborted by the server and Close usually
+// returns an error.
+// No other commands can be sent on the connection until the reader is closed,
+// they fail with ErrTransferInProgress.
+// The file is read as binary data.
+// The FTP command this sends is RETR.
+func (c *Connection) Retrieve(path string) (io.ReadCloser, error) {
+	return c.RetrieveFrom(path, 0)
+}
+
+// Store starts uploading a file to the given path on the server and returns a
+// writer for its contents. This is useful if the data is produced while it is
+// written, e.g. by a gzip.Writer or tar.Writer, instead of using Upload. If the
+// file was there before, it is overwritten. Otherwise a new file is created.
+// You have to Close the returned writer to complete the upload. Close returns
+// an error if the server reports that the transfer failed.
+// No other commands can be sent on the connection until the writer is closed,
+// they fail with ErrTransferInProgress.
+// The file is written as binary data.
+// The FTP command this sends is STOR.
+func (c *Connection) Store(path string) (io.WriteCloser, error) {
+	return c.store("STOR", path)
+}
+
+// StoreUnique is like Store but the server chooses a unique file name in the
+// current working directory.
+// The FTP command this sends is STOU.
+func (c *Connection) StoreUnique() (io.WriteCloser, error) {
+	return c.store("STOU", "")
+}
+
+// StoreAppend is like Store but appends the written data to the file at the
+// given path if it exists.
+// The FTP command this sends is APPE.
+func (c *Connection) StoreAppend(path string) (io.WriteCloser, error) {
+	return c.store("APPE", path)
+}
+
+func (c *Connection) store(cmd, path string) (io.WriteCloser, error) {
+	err := c.setBinaryTransfer()
+	if err != nil {
+		return nil, err
+	}
+	return c.openStream(cmd, path, 0)
+}
+
+// transferStream is the data connection of a transfer that is in progress.
+// Closing it finishes the transfer.
+type transferStream struct {
+	c        *Connection
+	cmd      string
+	dataConn net.Conn
+	stop     func() (interrupted bool)
+	closed   bool
+	reply    *Reply
+}
+
+// openStream starts a transfer and returns its data connection. The
+// connection is blocked for other commands until the stream is closed.
+func (c *Connection) openStream(cmd, arg string, offset int64) (*transferStream, error) {
+	dataConn, err := c.startTransfer(cmd, arg, offset)
+	if err != nil {
+		return nil, err
+	}
+	c.streaming = true
+	return &transferStream{
+		c:        c,
+		cmd:      cmd,
+		dataConn: dataConn,
+		stop:     c.interruptOnCancel(func() { dataConn.Close() }),
+	}, nil
+}
+
+func (s *transferStream) Read(p []byte) (int, error) {
+	n, err := s.dataConn.Read(p)
+	return n, s.contextError(err)
+}
+
+func (s *transferStream) Write(p []byte) (int, error) {
+	n, err := s.dataConn.Write(p)
+	return n, s.contextError(err)
+}
+
+// contextError replaces the error of an I/O operation with the context's error
+// if the data connection was closed because the context is done.
+func (s *transferStream) contextError(err error) error {
+	if err != nil && err != io.EOF {
+		if ctxErr := s.c.context().Err(); ctxErr != nil {
+			return ctxErr
+		}
+	}
+	return err
+}
+
+// Close finishes the transfer and reads the server's final reply. If the
+// context was cancelled, the transfer is aborted and the context's error is
+// returned.
+func (s *transferStream) Close() error {
+	if s.closed {
+		return errors.New("ftp: transfer stream already closed")
+	}
+	s.closed = true
+	s.c.streaming = false
+	if s.stop() {
+		return s.c.abortTransfer(s.dataConn)
+	}
+	reply, err := s.c.finishTransfer(s.cmd, s.dataConn)
+	s.reply = reply
+	return err
+}
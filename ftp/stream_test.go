@@ -0,0 +1,96 @@
+package ftp
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+func TestCommandsFailWhileStreamIsOpen(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	stream := openTestStream(c)
+	defer stream.dataConn.Close()
+
+	_, err := c.Quote("NOOP")
+	if err != ErrTransferInProgress {
+		t.Errorf("expected ErrTransferInProgress but got %v", err)
+	}
+
+	go server.Write([]byte("226 Transfer complete\r\n"))
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		bufio.NewReader(server).ReadString('\n')
+		server.Write([]byte("200 NOOP ok\r\n"))
+	}()
+	if _, err := c.Quote("NOOP"); err != nil {
+		t.Errorf("expected commands to work after Close but got %v", err)
+	}
+}
+
+func TestStreamReadsDataAndFinalReply(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	stream := openTestStream(c)
+	data := stream.dataConn.(*testDataConn).peer
+	go func() {
+		data.Write([]byte("file content"))
+		data.Close()
+	}()
+
+	content, err := ioutil.ReadAll(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "file content" {
+		t.Errorf("unexpected content %q", content)
+	}
+	go server.Write([]byte("451 Local error\r\n"))
+	if err := stream.Close(); !IsTransient(err) {
+		t.Errorf("expected transient error from final reply but got %v", err)
+	}
+}
+
+func TestClosingWriteStreamFinishesUpload(t *testing.T) {
+	c, server := pipeConnection()
+	defer c.Close()
+	stream := openTestStream(c)
+	data := stream.dataConn.(*testDataConn).peer
+	received := make(chan string, 1)
+	go func() {
+		content, _ := ioutil.ReadAll(data)
+		received <- string(content)
+		server.Write([]byte("226 Transfer complete\r\n"))
+	}()
+
+	if _, err := stream.Write([]byte("uploaded")); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if content := <-received; content != "uploaded" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
+
+// test helpers
+
+type testDataConn struct {
+	net.Conn
+	peer net.Conn
+}
+
+func openTestStream(c *Connection) *transferStream {
+	client, server := net.Pipe()
+	c.streaming = true
+	return &transferStream{
+		c:        c,
+		cmd:      "RETR",
+		dataConn: &testDataConn{Conn: client, peer: server},
+		stop:     c.interruptOnCancel(func() {}),
+	}
+}
@@ -0,0 +1,160 @@
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// ConnectTLS establishes a connection to the given host on the given port
+// using implicit FTPS, i.e. TLS is negotiated right after connecting, before
+// the server sends its greeting. All data connections are secured with TLS as
+// well. If config is nil or its ServerName is empty, the host is used to
+// verify the server's certificate. Data connections resume the TLS session of
+// the control connection, see AuthTLS.
+// Use ConnectConfig with a Config.TLSConfig to also set a context, a Logger or
+// time-outs.
+// The standard implicit FTPS port is 990.
+// The FTP commands this sends are PBSZ 0 and PROT P.
+func ConnectTLS(host string, port uint16, config *tls.Config) (*Connection, error) {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	return ConnectConfig(context.Background(), host, port, Config{TLSConfig: config})
+}
+
+// handshake starts a TLS session as the client on the given connection. If
+// timeout is not 0, it limits how long the handshake may take.
+func handshake(ctx context.Context, conn net.Conn, config *tls.Config, timeout time.Duration) (*tls.Conn, error) {
+	tlsConn := tls.Client(conn, config)
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+	stop := interruptOnCancel(ctx, expire(conn))
+	err := tlsConn.Handshake()
+	if stop() {
+		err = ctx.Err()
+	}
+	return tlsConn, err
+}
+
+// AuthTLS upgrades the control connection to TLS as described in RFC 4217
+// (explicit FTPS). After it succeeds, all messages on the control connection
+// are encrypted and every data connection that is opened for a transfer or
+// listing is wrapped in TLS as well, using the given configuration.
+// If config is nil or its ServerName is empty, the name of the host that was
+// used to connect is used to verify the server's certificate.
+// Data connections resume the TLS session of the control connection, which
+// some servers require. That is why config.ClientSessionCache is replaced with
+// a cache that belongs to this connection only.
+// Call this right after connecting and before you Login, otherwise the user
+// name and password are sent in clear-text.
+// The FTP commands this sends are AUTH TLS, PBSZ 0 and PROT P.
+func (c *Connection) AuthTLS(config *tls.Config) error {
+	err := c.execute(CodeSecurityDataExchangeComplete, "AUTH", "TLS")
+	if err != nil {
+		return err
+	}
+	c.featureSet = nil
+	config = tlsConfigFor(config, c.host)
+	tlsConn, err := handshake(c.context(), c.conn, config, c.config.ReplyTimeout)
+	if err != nil {
+		return err
+	}
+	c.conn = tlsConn
+	return c.protectDataConnections(config)
+}
+
+// protectDataConnections tells the server that all data connections will use
+// TLS from now on and remembers to wrap them with the given configuration.
+func (c *Connection) protectDataConnections(config *tls.Config) error {
+	// TLS does its own buffering so the protection buffer size is always 0.
+	err := c.execute(CodeCommandOK, "PBSZ", "0")
+	if err != nil {
+		return err
+	}
+	err = c.execute(CodeCommandOK, "PROT", "P")
+	if err != nil {
+		return err
+	}
+	c.tlsConfig = dataTLSConfig(config)
+	return nil
+}
+
+// tlsConfigFor returns a copy of the given configuration for a control
+// connection. It has its ServerName set to host unless it was set before and
+// it gets a new session cache for this control connection only. The caller's
+// configuration is never modified.
+func tlsConfigFor(config *tls.Config, host string) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	config = config.Clone()
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	config.ClientSessionCache = &controlSessionCache{}
+	return config
+}
+
+// dataTLSConfig returns the configuration for data connections which resume
+// the session of the control connection that uses the given configuration.
+// Servers like vsftpd with require_ssl_reuse or FileZilla Server reject data
+// connections that do not reuse the control connection's TLS session.
+func dataTLSConfig(control *tls.Config) *tls.Config {
+	config := control.Clone()
+	config.ClientSessionCache = readOnlySessionCache{control.ClientSessionCache}
+	return config
+}
+
+// controlSessionCache holds the TLS session of a single control connection.
+// The key is ignored so data connections find the session even if they have
+// no ServerName set and thus use their own address as the key.
+type controlSessionCache struct {
+	mutex   sync.Mutex
+	session *tls.ClientSessionState
+}
+
+func (c *controlSessionCache) Get(string) (*tls.ClientSessionState, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.session, c.session != nil
+}
+
+func (c *controlSessionCache) Put(_ string, session *tls.ClientSessionState) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.session = session
+}
+
+// readOnlySessionCache is used for data connections. They may resume the
+// control connection's session but must not replace it with their own.
+type readOnlySessionCache struct {
+	tls.ClientSessionCache
+}
+
+func (readOnlySessionCache) Put(string, *tls.ClientSessionState) {}
+
+// secureDataConnection wraps the given data connection in TLS if the control
+// connection requested protected data connections. Note that the client is
+// always the TLS client, even for data connections initiated by the server.
+// The handshake is done right away. Otherwise it would only happen on the
+// first Read or Write and closing the connection after an empty upload would
+// not send a TLS close_notify, which makes servers reject the transfer.
+func (c *Connection) secureDataConnection(conn net.Conn) (net.Conn, error) {
+	if c.tlsConfig == nil {
+		return conn, nil
+	}
+	tlsConn, err := handshake(c.context(), conn, c.tlsConfig, c.config.DataTimeout)
+	if err != nil {
+		if ctxErr := c.context().Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("ftp: TLS handshake on data connection failed: %w", err)
+	}
+	return tlsConn, nil
+}
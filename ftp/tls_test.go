@@ -0,0 +1,173 @@
+package ftp
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTLSConfigGetsHostAsServerName(t *testing.T) {
+	checkServerName(t, tlsConfigFor(nil, "ftp.example.com"), "ftp.example.com")
+	checkServerName(t,
+		tlsConfigFor(&tls.Config{}, "ftp.example.com"),
+		"ftp.example.com")
+	checkServerName(t,
+		tlsConfigFor(&tls.Config{ServerName: "other.com"}, "ftp.example.com"),
+		"other.com")
+}
+
+func TestTLSConfigOfCallerIsNotModified(t *testing.T) {
+	original := &tls.Config{}
+	tlsConfigFor(original, "ftp.example.com")
+	checkServerName(t, original, "")
+}
+
+func TestDataConnectionsAreOnlySecuredAfterProtectionWasSet(t *testing.T) {
+	plain, server := net.Pipe()
+	c := &Connection{session: &session{}}
+	if conn, _ := c.secureDataConnection(plain); conn != plain {
+		t.Error("unprotected data connection was wrapped in TLS")
+	}
+	serverConfig, clientConfig := testTLSConfigs(t)
+	go tls.Server(server, serverConfig).Handshake()
+	c.tlsConfig = clientConfig
+	conn, err := c.secureDataConnection(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConn, isTLS := conn.(*tls.Conn); !isTLS || !tlsConn.ConnectionState().HandshakeComplete {
+		t.Error("protected data connection was not secured by a TLS handshake")
+	}
+}
+
+func TestDataConnectionsShareTheControlSession(t *testing.T) {
+	control := tlsConfigFor(nil, "ftp.example.com")
+	data := dataTLSConfig(control)
+	session := &tls.ClientSessionState{}
+	control.ClientSessionCache.Put("ftp.example.com", session)
+	if s, ok := data.ClientSessionCache.Get("127.0.0.1:50000"); !ok || s != session {
+		t.Error("data connection does not find the control session")
+	}
+	data.ClientSessionCache.Put("127.0.0.1:50000", nil)
+	if s, _ := control.ClientSessionCache.Get(""); s != session {
+		t.Error("data connection replaced the control session")
+	}
+}
+
+func TestEveryControlConnectionGetsItsOwnSessionCache(t *testing.T) {
+	shared := &tls.Config{}
+	a := tlsConfigFor(shared, "a.com")
+	b := tlsConfigFor(shared, "a.com")
+	if a.ClientSessionCache == b.ClientSessionCache {
+		t.Error("two control connections share a session cache")
+	}
+}
+
+func TestEmptyUploadOverTLSIsAccepted(t *testing.T) {
+	serverConfig, clientConfig := testTLSConfigs(t)
+	server := startTestServer(t)
+	server.tls = serverConfig
+	c := server.connect()
+	defer c.Close()
+	if err := c.AuthTLS(clientConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Upload(bytes.NewReader(nil), "empty.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := server.file("empty.txt"); !ok || len(data) != 0 {
+		t.Errorf("expected empty file but got %q (%v)", data, ok)
+	}
+}
+
+func TestImplicitTLSSecuresControlAndDataConnections(t *testing.T) {
+	serverConfig, clientConfig := testTLSConfigs(t)
+	server := startTestServer(t)
+	server.tls = serverConfig
+	server.implicitTLS = true
+	server.setFile("file.txt", []byte("secret"))
+
+	c, err := ConnectTLS("127.0.0.1", server.port(), clientConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, ok := c.conn.(*tls.Conn); !ok {
+		t.Error("expected control connection to use TLS")
+	}
+	if err := c.Login("user", "pass"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.Download("file.txt", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "secret" {
+		t.Errorf("unexpected data %q", buf.String())
+	}
+}
+
+func TestFailedDataHandshakeKeepsControlConnectionInStep(t *testing.T) {
+	serverConfig, clientConfig := testTLSConfigs(t)
+	server := startTestServer(t)
+	server.tls = serverConfig
+	server.rejectDataTLS = true
+	server.setFile("file.txt", []byte("data"))
+	c := server.connect()
+	defer c.Close()
+	if err := c.AuthTLS(clientConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Download("file.txt", &bytes.Buffer{}); err == nil {
+		t.Fatal("expected download to fail")
+	}
+	if err := c.NoOperation(); err != nil {
+		t.Errorf("control connection out of step after failed handshake: %v", err)
+	}
+}
+
+// test helpers
+
+func checkServerName(t *testing.T, config *tls.Config, expected string) {
+	if config.ServerName != expected {
+		t.Errorf("expected server name '%v' but was '%v'",
+			expected, config.ServerName)
+	}
+}
+
+// testTLSConfigs returns a server configuration with a self-signed certificate
+// and a client configuration that trusts it.
+func testTLSConfigs(t *testing.T) (server, client *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client = &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+	return server, client
+}
@@ -0,0 +1,237 @@
+package ftp
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseUnixListing parses the output of LIST on servers that format it like
+// "ls -l" on Unix, e.g.
+//
+//	drwxr-xr-x   2 owner group     4096 Jan 31 12:34 docs
+//	-rw-r--r--   1 owner group     1234 Dec 24  2019 my file.txt
+//	lrwxrwxrwx   1 owner group       11 Mar  3 10:00 logs -> /var/log
+//
+// Lines that cannot be parsed, like "total 12", are skipped. The link count is
+// available as the fact "unix.nlink", see Entry.Fact.
+// "ls -l" only shows the year for files that are older than half a year, for
+// newer files the year is chosen so that the time is not in the future. Times
+// are interpreted as UTC because the listing does not contain the server's
+// time zone.
+func ParseUnixListing(listing string) []*Entry {
+	return parseUnixListing(listing, time.Now())
+}
+
+func parseUnixListing(listing string, now time.Time) []*Entry {
+	return parseListingWith(listing, func(line string) (*Entry, bool) {
+		return parseUnixLine(line, now)
+	})
+}
+
+// parseUnixLine parses a single line of an "ls -l" listing. The columns are
+// permissions, link count, owner, group, size, date and name, where the link
+// count and group are missing on some servers. The date is found by its month
+// name, everything after it is the name so names may contain spaces.
+func parseUnixLine(line string, now time.Time) (*Entry, bool) {
+	fields := fieldsWithOffsets(line)
+	if len(fields) < 6 {
+		return nil, false
+	}
+	typ, mode, ok := parseUnixPermissions(fields[0].text)
+	if !ok {
+		return nil, false
+	}
+	for m := 2; m+3 < len(fields); m++ {
+		modTime, ok := parseUnixDate(fields[m].text, fields[m+1].text, fields[m+2].text, now)
+		if !ok {
+			continue
+		}
+		size, ok := parseUnixSize(fields[1:m])
+		if !ok {
+			continue
+		}
+		facts := map[string]string{
+			"type":      typ,
+			"size":      size,
+			"modify":    modTime.Format("20060102150405"),
+			"unix.mode": strconv.FormatUint(mode, 8),
+		}
+		links, owner, group := unixLinksOwnerAndGroup(fields[1 : m-1])
+		if links != "" {
+			facts["unix.nlink"] = links
+		}
+		if owner != "" {
+			facts["unix.owner"] = owner
+		}
+		if group != "" {
+			facts["unix.group"] = group
+		}
+		name := line[fields[m+3].offset:]
+		if typ == "OS.unix=symlink" {
+			if arrow := strings.Index(name, " -> "); arrow != -1 {
+				facts["unix.target"] = name[arrow+4:]
+				name = name[:arrow]
+			}
+		}
+		if typ == "dir" && name == "." {
+			facts["type"] = "cdir"
+		}
+		if typ == "dir" && name == ".." {
+			facts["type"] = "pdir"
+		}
+		e := NewEntry(name, facts)
+		e.raw = line
+		return e, true
+	}
+	return nil, false
+}
+
+// parseUnixPermissions parses a permission string like "drwxr-xr-x" into the
+// type fact and the octal mode. A trailing +, @ or . marks ACLs or extended
+// attributes and is ignored.
+func parseUnixPermissions(perm string) (typ string, mode uint64, ok bool) {
+	perm = strings.TrimRight(perm, "+@.")
+	if len(perm) != 10 {
+		return "", 0, false
+	}
+	switch perm[0] {
+	case '-':
+		typ = "file"
+	case 'd':
+		typ = "dir"
+	case 'l':
+		typ = "OS.unix=symlink"
+	case 'b':
+		typ = "OS.unix=blk"
+	case 'c':
+		typ = "OS.unix=chr"
+	case 'p':
+		typ = "OS.unix=fifo"
+	case 's':
+		typ = "OS.unix=socket"
+	default:
+		return "", 0, false
+	}
+	for i, c := range perm[1:] {
+		bit := uint64(1) << uint(8-i)
+		expected := "rwx"[i%3]
+		switch {
+		case byte(c) == expected:
+			mode |= bit
+		case c == '-':
+		case i%3 == 2 && (c == 's' || c == 't'):
+			mode |= bit | specialModeBit(i)
+		case i%3 == 2 && (c == 'S' || c == 'T'):
+			mode |= specialModeBit(i)
+		default:
+			return "", 0, false
+		}
+	}
+	return typ, mode, true
+}
+
+// specialModeBit returns the setuid, setgid or sticky bit for the execute
+// permission at the given index.
+func specialModeBit(i int) uint64 {
+	return 04000 >> uint(i/3)
+}
+
+var monthNames = []string{
+	"jan", "feb", "mar", "apr", "may", "jun",
+	"jul", "aug", "sep", "oct", "nov", "dec",
+}
+
+// parseUnixDate parses a date like "Jan 31 12:34" or "Dec 24 2019". If there is
+// no year, the date is assumed to be within the last year before now. A day of
+// tolerance allows for servers in other time zones.
+func parseUnixDate(month, day, timeOrYear string, now time.Time) (time.Time, bool) {
+	m := 0
+	for i, name := range monthNames {
+		if strings.EqualFold(month, name) {
+			m = i + 1
+		}
+	}
+	d, err := strconv.Atoi(day)
+	if m == 0 || err != nil || d < 1 || d > 31 {
+		return time.Time{}, false
+	}
+	if colon := strings.IndexByte(timeOrYear, ':'); colon != -1 {
+		hour, err1 := strconv.Atoi(timeOrYear[:colon])
+		minute, err2 := strconv.Atoi(timeOrYear[colon+1:])
+		if err1 != nil || err2 != nil || hour > 23 || minute > 59 {
+			return time.Time{}, false
+		}
+		t := time.Date(now.Year(), time.Month(m), d, hour, minute, 0, 0, time.UTC)
+		if t.After(now.Add(24 * time.Hour)) {
+			t = t.AddDate(-1, 0, 0)
+		}
+		return t, true
+	}
+	year, err := strconv.Atoi(timeOrYear)
+	if err != nil || len(timeOrYear) != 4 {
+		return time.Time{}, false
+	}
+	return time.Date(year, time.Month(m), d, 0, 0, 0, 0, time.UTC), true
+}
+
+// parseUnixSize returns the size, which is the last of the given fields.
+// Device files list their major and minor numbers instead, e.g. "1, 3", and
+// get size 0.
+func parseUnixSize(fields []field) (string, bool) {
+	if len(fields) == 0 {
+		return "", false
+	}
+	size := fields[len(fields)-1].text
+	if _, err := strconv.ParseUint(size, 10, 64); err != nil {
+		return "", false
+	}
+	if len(fields) >= 2 && strings.HasSuffix(fields[len(fields)-2].text, ",") {
+		return "0", true
+	}
+	return size, true
+}
+
+// unixLinksOwnerAndGroup extracts the link count, owner and group from the
+// fields between the permissions and the size.
+func unixLinksOwnerAndGroup(fields []field) (links, owner, group string) {
+	if len(fields) > 0 && strings.HasSuffix(fields[len(fields)-1].text, ",") {
+		fields = fields[:len(fields)-1]
+	}
+	if len(fields) > 0 {
+		if _, err := strconv.Atoi(fields[0].text); err == nil && len(fields) > 1 {
+			links = fields[0].text
+			fields = fields[1:]
+		}
+	}
+	if len(fields) > 0 {
+		owner = fields[0].text
+	}
+	if len(fields) > 1 {
+		group = fields[1].text
+	}
+	return
+}
+
+// field is a word of a line together with its byte offset in the line.
+type field struct {
+	text   string
+	offset int
+}
+
+// fieldsWithOffsets splits the line at runs of spaces and tabs.
+func fieldsWithOffsets(line string) []field {
+	var fields []field
+	start := -1
+	for i := 0; i <= len(line); i++ {
+		if i == len(line) || line[i] == ' ' || line[i] == '\t' {
+			if start != -1 {
+				fields = append(fields, field{line[start:i], start})
+				start = -1
+			}
+		} else if start == -1 {
+			start = i
+		}
+	}
+	return fields
+}
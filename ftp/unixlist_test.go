@@ -0,0 +1,95 @@
+package ftp
+
+import (
+	"io/fs"
+	"testing"
+	"time"
+)
+
+func TestUnixListingIsParsedIntoEntries(t *testing.T) {
+	now := time.Date(2020, 6, 15, 12, 0, 0, 0, time.UTC)
+	entries := parseUnixListing("total 12\r\n"+
+		"drwxr-xr-x   2 bob  staff     4096 Jan 31 12:34 docs\r\n"+
+		"-rw-r--r--   1 bob  staff     1234 Dec 24  2019 my  file.txt\r\n"+
+		"lrwxrwxrwx   1 root root        11 Mar  3 10:00 logs -> /var/log\r\n"+
+		"-rwsr-xr-t+  1 0    0            7 Jun  1 08:15 suid\r\n", now)
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries but got %d", len(entries))
+	}
+
+	dir := entries[0]
+	checkEntryKind(t, dir, "docs", EntryDir)
+	checkModTime(t, dir, time.Date(2020, 1, 31, 12, 34, 0, 0, time.UTC))
+	if mode, ok := dir.UnixMode(); !ok || mode != 0755 {
+		t.Errorf("expected mode 0755 but got %v", mode)
+	}
+	if dir.Owner() != "bob" || dir.Group() != "staff" || dir.Size() != 4096 {
+		t.Errorf("unexpected owner %q, group %q or size %d",
+			dir.Owner(), dir.Group(), dir.Size())
+	}
+	checkLinkCount(t, dir, "2")
+
+	file := entries[1]
+	checkEntryKind(t, file, "my  file.txt", EntryFile)
+	checkModTime(t, file, time.Date(2019, 12, 24, 0, 0, 0, 0, time.UTC))
+
+	link := entries[2]
+	checkEntryKind(t, link, "logs", EntrySymlink)
+	if link.Target() != "/var/log" {
+		t.Errorf("expected target /var/log but got %q", link.Target())
+	}
+
+	suid := entries[3]
+	checkEntryKind(t, suid, "suid", EntryFile)
+	if mode, _ := suid.UnixMode(); mode != fs.ModeSetuid|fs.ModeSticky|0755 {
+		t.Errorf("expected setuid and sticky bits but got %v", mode)
+	}
+}
+
+func TestRecentDatesWithoutYearAreNotInTheFuture(t *testing.T) {
+	now := time.Date(2020, 1, 10, 12, 0, 0, 0, time.UTC)
+	entries := parseUnixListing(
+		"-rw-r--r-- 1 bob staff 1 Dec 31 23:59 old\n"+
+			"-rw-r--r-- 1 bob staff 1 Jan 10 13:00 today\n", now)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries but got %d", len(entries))
+	}
+	checkModTime(t, entries[0], time.Date(2019, 12, 31, 23, 59, 0, 0, time.UTC))
+	checkModTime(t, entries[1], time.Date(2020, 1, 10, 13, 0, 0, 0, time.UTC))
+}
+
+func TestUnixListingWithoutGroupAndDeviceFiles(t *testing.T) {
+	now := time.Date(2020, 6, 15, 12, 0, 0, 0, time.UTC)
+	entries := parseUnixListing(
+		"-rw-r--r-- 1 bob 1234 Feb  2  2018 no group\n"+
+			"crw-rw-rw- 1 root root 1, 3 Feb  2  2018 null\n"+
+			"drwxr-xr-x 3 bob staff 4096 Feb  2  2018 .\n", now)
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries but got %d", len(entries))
+	}
+	checkEntryKind(t, entries[0], "no group", EntryFile)
+	if entries[0].Owner() != "bob" || entries[0].Group() != "" || entries[0].Size() != 1234 {
+		t.Errorf("unexpected owner %q, group %q or size %d",
+			entries[0].Owner(), entries[0].Group(), entries[0].Size())
+	}
+	checkLinkCount(t, entries[0], "1")
+	checkEntryKind(t, entries[1], "null", EntryOther)
+	if entries[1].Owner() != "root" || entries[1].Group() != "root" {
+		t.Errorf("unexpected owner %q or group %q", entries[1].Owner(), entries[1].Group())
+	}
+	checkEntryKind(t, entries[2], ".", EntryCurrentDir)
+}
+
+// test helpers
+
+func checkModTime(t *testing.T, e *Entry, expected time.Time) {
+	if !e.ModTime().Equal(expected) {
+		t.Errorf("expected %s to be modified at %v but was %v", e.Name(), expected, e.ModTime())
+	}
+}
+
+func checkLinkCount(t *testing.T, e *Entry, expected string) {
+	if links, ok := e.Fact("unix.nlink"); !ok || links != expected {
+		t.Errorf("expected link count %v for %q but got %q", expected, e.Name(), links)
+	}
+}
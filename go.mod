@@ -1,3 +1,3 @@
 module github.com/gonutz/ftp-client
 
-go 1.11
+go 1.16